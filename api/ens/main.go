@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/manta-coder/golang-serverless-example/pkg/engine"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/service"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	logger      *zap.SugaredLogger
+	userService service.UserService
+	maxAge      time.Duration
+	batchSize   uint64
+	provider    *tracing.Provider
+	metrics     server.Metrics
+)
+
+func init() {
+	c := engine.MustContainer(engine.MustLoadConfig(engine.RequireEnsRefresh))
+
+	userService = c.UserService()
+	logger = c.Server.Logger
+	provider = c.Server.Tracing
+	metrics = c.Server.Metrics
+	maxAge = time.Duration(c.Config.EnsRefreshMaxAgeSeconds) * time.Second
+	batchSize = c.Config.EnsRefreshBatchSize
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) error {
+	refreshed, err := userService.RefreshStaleEns(ctx, maxAge, batchSize)
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("refreshed ens profiles", "users", refreshed)
+
+	return nil
+}
+
+func main() {
+	lambda.StartHandler(server.MetricsHandler(metrics, provider.Handler(lambda.NewHandler(handler))))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/engine"
+	"github.com/manta-coder/golang-serverless-example/pkg/indexer"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/service"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+)
+
+var (
+	indexerService service.IndexerService
+	provider       *tracing.Provider
+	metrics        server.Metrics
+)
+
+func init() {
+	c := engine.MustContainer(engine.MustLoadConfig(engine.RequireIndexer))
+	config := c.Config
+
+	chainID := config.IndexerChainID
+	if chainID == 0 {
+		chainID = domain.ChainIDMainnet
+	}
+
+	source, err := indexer.NewRPCSource(context.Background(), config.RPCURL(chainID))
+	if err != nil {
+		c.Server.Logger.Fatalw("unable to connect to ethereum node", "err", err)
+	}
+
+	provider = c.Server.Tracing
+	metrics = c.Server.Metrics
+	indexerService = service.NewTracedIndexerService(service.NewIndexerService(c.Server.Logger, service.IndexerConfig{
+		Name:              fmt.Sprintf("erc721-%d", chainID),
+		ChainID:           chainID,
+		Contracts:         config.IndexerContracts,
+		CharacterContract: config.IndexerCharacterContract,
+		StartBlock:        config.IndexerStartBlock,
+		BatchSize:         config.IndexerBatchSize,
+		Confirmations:     config.IndexerConfirmations,
+	}, source, c.OwnershipStore(), c.UserService()))
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) error {
+	return indexerService.Sync(ctx)
+}
+
+func main() {
+	lambda.StartHandler(server.MetricsHandler(metrics, provider.Handler(lambda.NewHandler(handler))))
+}
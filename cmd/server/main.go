@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/manta-coder/golang-serverless-example/pkg/engine"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests get to complete once a signal is received
+const shutdownTimeout = 10 * time.Second
+
+type serverConfig struct {
+	engine.Config
+	HTTPAddress string `env:"HTTP_ADDRESS" envDefault:":8080"`
+}
+
+// main serves every registered module over plain HTTP for local development, with the same wiring as the lambdas
+func main() {
+	modules := engine.Modules()
+
+	var config serverConfig
+	if err := engine.LoadConfig(&config); err != nil {
+		panic(fmt.Errorf("failed to load config: %w", err))
+	}
+
+	// lambdas push their metrics to CloudWatch, the standalone server is scraped by Prometheus
+	config.MetricsBackend = server.MetricsBackendPrometheus
+
+	if err := config.Validate(engine.Requirements(modules...)...); err != nil {
+		panic(fmt.Errorf("invalid config: %w", err))
+	}
+
+	server := engine.MustBoot(config.Config, modules...).Server
+	defer server.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		server.Logger.Infow("listening", "address", config.HTTPAddress)
+
+		if err := server.Echo.Start(config.HTTPAddress); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			server.Logger.Errorw("server stopped", "err", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	server.Logger.Infow("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Echo.Shutdown(shutdownCtx); err != nil {
+		server.Logger.Errorw("unable to shut down gracefully", "err", err)
+	}
+}
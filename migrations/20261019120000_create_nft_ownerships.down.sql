@@ -0,0 +1,3 @@
+DROP TABLE indexer_cursors;
+DROP TABLE nft_ownerships;
+DROP TABLE nft_transfers;
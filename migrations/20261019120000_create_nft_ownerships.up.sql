@@ -0,0 +1,37 @@
+CREATE TABLE nft_transfers
+(
+    contract_address TEXT        NOT NULL,
+    token_id         NUMERIC(78) NOT NULL,
+    from_address     TEXT        NOT NULL,
+    to_address       TEXT        NOT NULL,
+    block_number     BIGINT      NOT NULL,
+    block_hash       TEXT        NOT NULL,
+    log_index        INTEGER     NOT NULL,
+    transaction_hash TEXT        NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (block_number, log_index)
+);
+
+CREATE INDEX nft_transfers_token_idx ON nft_transfers (contract_address, token_id, block_number DESC, log_index DESC);
+
+CREATE TABLE nft_ownerships
+(
+    contract_address TEXT        NOT NULL,
+    token_id         NUMERIC(78) NOT NULL,
+    owner_address    TEXT        NOT NULL,
+    block_number     BIGINT      NOT NULL,
+    log_index        INTEGER     NOT NULL,
+    updated_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (contract_address, token_id)
+);
+
+CREATE INDEX nft_ownerships_owner_idx ON nft_ownerships (owner_address);
+CREATE INDEX nft_ownerships_block_idx ON nft_ownerships (block_number);
+
+CREATE TABLE indexer_cursors
+(
+    name         TEXT PRIMARY KEY,
+    block_number BIGINT      NOT NULL,
+    block_hash   TEXT        NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+DROP INDEX users_ens_refreshed_at_idx;
+DROP INDEX users_ens_name_idx;
+
+ALTER TABLE users
+    DROP COLUMN ens_refreshed_at,
+    DROP COLUMN ens_avatar,
+    DROP COLUMN ens_name;
@@ -0,0 +1,7 @@
+ALTER TABLE users
+    ADD COLUMN ens_name         TEXT,
+    ADD COLUMN ens_avatar       TEXT,
+    ADD COLUMN ens_refreshed_at TIMESTAMPTZ;
+
+CREATE INDEX users_ens_name_idx ON users (ens_name);
+CREATE INDEX users_ens_refreshed_at_idx ON users (ens_refreshed_at NULLS FIRST);
@@ -0,0 +1,25 @@
+UPDATE indexer_cursors SET name = 'erc721' WHERE name = 'erc721-1';
+
+ALTER TABLE indexer_cursors
+    DROP COLUMN chain_id;
+
+DROP INDEX nft_ownerships_owner_idx;
+DROP INDEX nft_ownerships_block_idx;
+CREATE INDEX nft_ownerships_owner_idx ON nft_ownerships (owner_address);
+CREATE INDEX nft_ownerships_block_idx ON nft_ownerships (block_number);
+
+ALTER TABLE nft_ownerships
+    DROP CONSTRAINT nft_ownerships_pkey,
+    DROP COLUMN chain_id,
+    ADD PRIMARY KEY (contract_address, token_id);
+
+DROP INDEX nft_transfers_token_idx;
+CREATE INDEX nft_transfers_token_idx ON nft_transfers (contract_address, token_id, block_number DESC, log_index DESC);
+
+ALTER TABLE nft_transfers
+    DROP CONSTRAINT nft_transfers_pkey,
+    DROP COLUMN chain_id,
+    ADD PRIMARY KEY (block_number, log_index);
+
+ALTER TABLE challenges
+    DROP COLUMN chain_id;
@@ -0,0 +1,26 @@
+ALTER TABLE challenges
+    ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE nft_transfers
+    ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1,
+    DROP CONSTRAINT nft_transfers_pkey,
+    ADD PRIMARY KEY (chain_id, block_number, log_index);
+
+DROP INDEX nft_transfers_token_idx;
+CREATE INDEX nft_transfers_token_idx ON nft_transfers (chain_id, contract_address, token_id, block_number DESC, log_index DESC);
+
+ALTER TABLE nft_ownerships
+    ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1,
+    DROP CONSTRAINT nft_ownerships_pkey,
+    ADD PRIMARY KEY (chain_id, contract_address, token_id);
+
+DROP INDEX nft_ownerships_owner_idx;
+DROP INDEX nft_ownerships_block_idx;
+CREATE INDEX nft_ownerships_owner_idx ON nft_ownerships (chain_id, owner_address);
+CREATE INDEX nft_ownerships_block_idx ON nft_ownerships (chain_id, block_number);
+
+ALTER TABLE indexer_cursors
+    ADD COLUMN chain_id BIGINT NOT NULL DEFAULT 1;
+
+-- cursors are now named after their chain
+UPDATE indexer_cursors SET name = 'erc721-1' WHERE name = 'erc721';
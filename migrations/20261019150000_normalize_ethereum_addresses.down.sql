@@ -0,0 +1,10 @@
+ALTER TABLE nft_ownerships
+    DROP CONSTRAINT nft_ownerships_addresses_lowercase;
+
+ALTER TABLE nft_transfers
+    DROP CONSTRAINT nft_transfers_addresses_lowercase;
+
+ALTER TABLE users
+    DROP CONSTRAINT users_ethereum_address_lowercase;
+
+DROP INDEX users_ethereum_address_key;
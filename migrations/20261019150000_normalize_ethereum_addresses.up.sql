@@ -0,0 +1,50 @@
+-- addresses are stored lowercase, accounts that only differ by casing have to be merged by hand first
+DO
+$$
+    BEGIN
+        IF EXISTS(SELECT 1 FROM users GROUP BY lower(ethereum_address) HAVING count(*) > 1) THEN
+            RAISE EXCEPTION 'users contains duplicate ethereum addresses with different casing';
+        END IF;
+    END
+$$;
+
+UPDATE users
+SET ethereum_address = lower(ethereum_address)
+WHERE ethereum_address <> lower(ethereum_address);
+
+UPDATE challenges
+SET ethereum_address = lower(ethereum_address)
+WHERE ethereum_address <> lower(ethereum_address);
+
+CREATE UNIQUE INDEX users_ethereum_address_key ON users (ethereum_address);
+
+ALTER TABLE users
+    ADD CONSTRAINT users_ethereum_address_lowercase CHECK (ethereum_address = lower(ethereum_address));
+
+-- the indexer wrote checksummed addresses, it never wrote the same address in two casings
+UPDATE nft_transfers
+SET contract_address = lower(contract_address),
+    from_address     = lower(from_address),
+    to_address       = lower(to_address)
+WHERE contract_address <> lower(contract_address)
+   OR from_address <> lower(from_address)
+   OR to_address <> lower(to_address);
+
+UPDATE nft_ownerships
+SET contract_address = lower(contract_address),
+    owner_address    = lower(owner_address)
+WHERE contract_address <> lower(contract_address)
+   OR owner_address <> lower(owner_address);
+
+ALTER TABLE nft_transfers
+    ADD CONSTRAINT nft_transfers_addresses_lowercase CHECK (
+        contract_address = lower(contract_address) AND
+        from_address = lower(from_address) AND
+        to_address = lower(to_address)
+        );
+
+ALTER TABLE nft_ownerships
+    ADD CONSTRAINT nft_ownerships_addresses_lowercase CHECK (
+        contract_address = lower(contract_address) AND
+        owner_address = lower(owner_address)
+        );
@@ -0,0 +1,3 @@
+ALTER TABLE nft_transfers
+    DROP CONSTRAINT nft_transfers_pkey,
+    ADD PRIMARY KEY (chain_id, block_number, log_index);
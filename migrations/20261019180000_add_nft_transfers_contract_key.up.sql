@@ -0,0 +1,4 @@
+-- transfers are keyed by the contract that emitted them, so replaying or rolling back a contract never touches another
+ALTER TABLE nft_transfers
+    DROP CONSTRAINT nft_transfers_pkey,
+    ADD PRIMARY KEY (chain_id, contract_address, block_number, log_index);
@@ -0,0 +1,2 @@
+DROP INDEX users_ens_name_key;
+CREATE INDEX users_ens_name_idx ON users (ens_name);
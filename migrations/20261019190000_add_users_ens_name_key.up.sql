@@ -0,0 +1,14 @@
+-- a name reverse resolves to a single address, the users holding a stale copy are refreshed again first
+UPDATE users
+SET ens_name         = NULL,
+    ens_avatar       = NULL,
+    ens_refreshed_at = NULL
+WHERE user_id IN (SELECT user_id
+                  FROM (SELECT user_id,
+                               row_number() OVER (PARTITION BY ens_name ORDER BY ens_refreshed_at DESC NULLS LAST) AS n
+                        FROM users
+                        WHERE ens_name IS NOT NULL) ranked
+                  WHERE n > 1);
+
+DROP INDEX users_ens_name_idx;
+CREATE UNIQUE INDEX users_ens_name_key ON users (ens_name) WHERE ens_name IS NOT NULL;
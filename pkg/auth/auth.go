@@ -7,17 +7,22 @@ import (
 )
 
 type Claims struct {
-	UserID             string `json:"user_id"`
-	EthereumAddressHex string `json:"ethereum_address"`
+	UserID          string                 `json:"user_id"`
+	EthereumAddress domain.EthereumAddress `json:"ethereum_address"`
+	ChainID         domain.ChainID         `json:"chain_id"`
+	// Locale is the preferred locale of the user when the token was issued, empty when there's none
+	Locale string `json:"locale,omitempty"`
 	jwt.StandardClaims
 }
 
-func newClaims(userID string, address domain.EthereumAddress, d time.Duration) *Claims {
+func newClaims(userID string, wallet domain.Wallet, locale string, d time.Duration) *Claims {
 	now := time.Now()
 
 	return &Claims{
-		UserID:             userID,
-		EthereumAddressHex: address.Hex(),
+		UserID:          userID,
+		EthereumAddress: wallet.Address,
+		ChainID:         wallet.ChainID,
+		Locale:          locale,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: now.Add(d).Unix(),
 			IssuedAt:  now.Unix(),
@@ -25,13 +30,26 @@ func newClaims(userID string, address domain.EthereumAddress, d time.Duration) *
 	}
 }
 
+// Chain returns the chain the user signed in for. Tokens issued before chains were supported are for mainnet
+func (claims *Claims) Chain() domain.ChainID {
+	if claims.ChainID == 0 {
+		return domain.ChainIDMainnet
+	}
+	return claims.ChainID
+}
+
+// Wallet returns the wallet the user signed in with
+func (claims *Claims) Wallet() domain.Wallet {
+	return domain.NewWallet(claims.Chain(), claims.EthereumAddress)
+}
+
 type token struct {
 	*jwt.Token
 }
 
-func newToken(userID string, address domain.EthereumAddress, d time.Duration) *token {
+func newToken(userID string, wallet domain.Wallet, locale string, d time.Duration) *token {
 	return &token{jwt.NewWithClaims(
-		jwt.SigningMethodHS256, newClaims(userID, address, d),
+		jwt.SigningMethodHS256, newClaims(userID, wallet, locale, d),
 	)}
 }
 
@@ -5,29 +5,29 @@ import (
 )
 
 type ChallengeInput struct {
-	domain.EthereumAddressHexInput
+	domain.WalletInput
 }
 
-func NewChallengeInput(addressHex string) ChallengeInput {
+func NewChallengeInput(addressHex string, chainID domain.ChainID) ChallengeInput {
 	return ChallengeInput{
-		EthereumAddressHexInput: domain.NewEthereumAddressHexInput(addressHex),
+		WalletInput: domain.NewWalletInput(addressHex, chainID),
 	}
 }
 
 type AuthorizeInput struct {
-	domain.EthereumAddressHexInput
+	domain.WalletInput
 	SigHex string
 }
 
-func NewAuthorizeInput(addressHex, sigHex string) AuthorizeInput {
+func NewAuthorizeInput(addressHex string, chainID domain.ChainID, sigHex string) AuthorizeInput {
 	return AuthorizeInput{
-		EthereumAddressHexInput: domain.NewEthereumAddressHexInput(addressHex),
-		SigHex:                  sigHex,
+		WalletInput: domain.NewWalletInput(addressHex, chainID),
+		SigHex:      sigHex,
 	}
 }
 
 func (input AuthorizeInput) Validate() error {
-	if err := input.EthereumAddressHexInput.Validate(); err != nil {
+	if err := input.WalletInput.Validate(); err != nil {
 		return err
 	}
 	if err := domain.ValidateSignatureHex(input.SigHex); err != nil {
@@ -41,17 +41,17 @@ func (input AuthorizeInput) Signature() domain.Signature {
 }
 
 type AuthorizeSilentlyInput struct {
-	domain.EthereumAddressHexInput
+	domain.WalletInput
 }
 
-func NewAuthorizeSilentlyInput(addressHex string) AuthorizeSilentlyInput {
+func NewAuthorizeSilentlyInput(addressHex string, chainID domain.ChainID) AuthorizeSilentlyInput {
 	return AuthorizeSilentlyInput{
-		EthereumAddressHexInput: domain.NewEthereumAddressHexInput(addressHex),
+		WalletInput: domain.NewWalletInput(addressHex, chainID),
 	}
 }
 
 func (input AuthorizeSilentlyInput) Validate() error {
-	if err := input.EthereumAddressHexInput.Validate(); err != nil {
+	if err := input.WalletInput.Validate(); err != nil {
 		return err
 	}
 	return nil
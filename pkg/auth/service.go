@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"fmt"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
@@ -15,26 +16,63 @@ const (
 type Service struct {
 	secret              string
 	tokenExpiryDuration time.Duration
+	chainIDs            []domain.ChainID
 }
 
-func NewService(secret string, ted time.Duration) *Service {
+// NewService creates an auth service accepting sign-ins for chainIDs, the first one being the default.
+// Only mainnet is accepted if no chain is provided
+func NewService(secret string, ted time.Duration, chainIDs ...domain.ChainID) *Service {
+	if len(chainIDs) == 0 {
+		chainIDs = []domain.ChainID{domain.ChainIDMainnet}
+	}
+
 	return &Service{
 		secret:              secret,
 		tokenExpiryDuration: ted,
+		chainIDs:            chainIDs,
+	}
+}
+
+// DefaultChainID is used when clients don't specify a chain
+func (s *Service) DefaultChainID() domain.ChainID {
+	return s.chainIDs[0]
+}
+
+func (s *Service) ValidateChainID(chainID domain.ChainID) error {
+	for _, id := range s.chainIDs {
+		if id == chainID {
+			return nil
+		}
 	}
+
+	return domain.ErrUnsupportedChain(nil)
 }
 
 func (s *Service) NewChallenge() string {
 	return helpers.Rand(ChallengeStringLength)
 }
 
-func (s *Service) VerifyChallenge(userChallenge domain.Challenge, responseBytes []byte) error {
+// ChallengeMessage is the message users sign for challenge. It names the chain like EIP-4361 so a signature can't be
+// replayed on another chain
+func ChallengeMessage(challenge string, chainID domain.ChainID) string {
+	return fmt.Sprintf("Sign in with your Ethereum account.\n\nChain ID: %d\nNonce: %s", chainID, challenge)
+}
+
+func (s *Service) VerifyChallenge(userChallenge domain.Challenge, chainID domain.ChainID, responseBytes []byte) error {
+	if err := s.ValidateChainID(chainID); err != nil {
+		return err
+	}
+	if userChallenge.ChainID != chainID {
+		return domain.ErrChainMismatch(nil)
+	}
+
 	if responseBytes[domain.SignatureSize-1] >= domain.SignatureRIRangeBase {
 		responseBytes[domain.SignatureSize-1] -= domain.SignatureRIRangeBase
 	}
 
 	// Hash the unsigned message using EIP-191
-	hashedMessage := []byte("\x19Ethereum Signed Message:\n" + strconv.Itoa(len(userChallenge.Challenge)) + userChallenge.Challenge)
+	message := ChallengeMessage(userChallenge.Challenge, chainID)
+	hashedMessage := []byte("\x19Ethereum Signed Message:\n" + strconv.Itoa(len(message)) + message)
 	hash := crypto.Keccak256Hash(hashedMessage)
 
 	publicKey, err := crypto.SigToPub(
@@ -45,13 +83,15 @@ func (s *Service) VerifyChallenge(userChallenge domain.Challenge, responseBytes
 		return err
 	}
 
-	if address := domain.EthereumAddress(crypto.PubkeyToAddress(*publicKey)); address.Hex() != userChallenge.EthereumAddressHex {
+	if address := domain.EthereumAddress(crypto.PubkeyToAddress(*publicKey)); address != userChallenge.EthereumAddress {
 		return domain.ErrInvalidSignature(nil)
 	}
 
 	return nil
 }
 
-func (s *Service) IssueToken(user domain.User) ([]byte, error) {
-	return newToken(user.UserID, domain.NewEthereumAddressFromHex(user.EthereumAddressHex), s.tokenExpiryDuration).signedBytes(s.secret)
+func (s *Service) IssueToken(user domain.User, chainID domain.ChainID) ([]byte, error) {
+	wallet := domain.NewWallet(chainID, user.EthereumAddress)
+
+	return newToken(user.UserID, wallet, user.Locale, s.tokenExpiryDuration).signedBytes(s.secret)
 }
@@ -10,8 +10,10 @@ import (
 	"time"
 )
 
+const testChainIDArbitrum domain.ChainID = 42161
+
 func createTestService() *Service {
-	return NewService("123456789abcdefghijklmnopqrstuvwyz", time.Duration(900)*time.Second)
+	return NewService("123456789abcdefghijklmnopqrstuvwyz", time.Duration(900)*time.Second, domain.ChainIDMainnet, testChainIDArbitrum)
 }
 
 func TestService_VerifyChallenge(t *testing.T) {
@@ -25,24 +27,57 @@ func TestService_VerifyChallenge(t *testing.T) {
 	assert.True(t, ok)
 
 	challenge := service.NewChallenge()
-	signedHash := tester.SignHash(challenge)
+	signedHash := tester.SignHash(ChallengeMessage(challenge, domain.ChainIDMainnet))
 
 	signatureBytes, err := crypto.Sign(signedHash.Bytes(), privateKey)
 	assert.NoError(t, err)
 
 	err = service.VerifyChallenge(domain.Challenge{
-		ChallengeID:        "",
-		Challenge:          challenge,
-		EthereumAddressHex: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)).Hex(),
-	}, signatureBytes)
+		ChallengeID:     "",
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       challenge,
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, domain.ChainIDMainnet, signatureBytes)
 	assert.NoError(t, err)
 
+	// challenge issued for another chain should fail
+	err = service.VerifyChallenge(domain.Challenge{
+		ChallengeID:     "",
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       challenge,
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, testChainIDArbitrum, signatureBytes)
+	if assert.IsType(t, &domain.Error{}, err) {
+		assert.Equal(t, domain.ErrChainMismatch(nil).Code, err.(*domain.Error).Code)
+	}
+
+	// the signature of a mainnet challenge can't be replayed on another chain
+	err = service.VerifyChallenge(domain.Challenge{
+		ChallengeID:     "",
+		ChainID:         testChainIDArbitrum,
+		Challenge:       challenge,
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, testChainIDArbitrum, signatureBytes)
+	if assert.IsType(t, &domain.Error{}, err) {
+		assert.Equal(t, domain.ErrInvalidSignature(nil).Code, err.(*domain.Error).Code)
+	}
+
+	// unsupported chain should fail
+	err = service.VerifyChallenge(domain.Challenge{
+		ChallengeID:     "",
+		ChainID:         10,
+		Challenge:       challenge,
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, 10, signatureBytes)
+	assert.Error(t, err)
+
 	// wrong challenge should fail
 	err = service.VerifyChallenge(domain.Challenge{
-		ChallengeID:        "",
-		Challenge:          service.NewChallenge(),
-		EthereumAddressHex: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)).Hex(),
-	}, signatureBytes)
+		ChallengeID:     "",
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       service.NewChallenge(),
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, domain.ChainIDMainnet, signatureBytes)
 	assert.Error(t, err)
 
 	// wrong signature should fail
@@ -50,10 +85,11 @@ func TestService_VerifyChallenge(t *testing.T) {
 	signatureBytes2, err := crypto.Sign(signedHash.Bytes(), privateKey2)
 	assert.NoError(t, err)
 	err = service.VerifyChallenge(domain.Challenge{
-		ChallengeID:        "",
-		Challenge:          service.NewChallenge(),
-		EthereumAddressHex: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)).Hex(),
-	}, signatureBytes2)
+		ChallengeID:     "",
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       service.NewChallenge(),
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)),
+	}, domain.ChainIDMainnet, signatureBytes2)
 	assert.Error(t, err)
 
 	// wrong public key should fail
@@ -61,9 +97,18 @@ func TestService_VerifyChallenge(t *testing.T) {
 	publicKeyECDSA2, ok := publicKey2.(*ecdsa.PublicKey)
 	assert.True(t, ok)
 	err = service.VerifyChallenge(domain.Challenge{
-		ChallengeID:        "",
-		Challenge:          service.NewChallenge(),
-		EthereumAddressHex: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA2)).Hex(),
-	}, signatureBytes)
+		ChallengeID:     "",
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       service.NewChallenge(),
+		EthereumAddress: domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA2)),
+	}, domain.ChainIDMainnet, signatureBytes)
 	assert.Error(t, err)
 }
+
+func TestChallengeMessage(t *testing.T) {
+	t.Parallel()
+
+	message := ChallengeMessage("nonce", testChainIDArbitrum)
+	assert.Contains(t, message, "\nChain ID: 42161\n")
+	assert.Contains(t, message, "\nNonce: nonce")
+}
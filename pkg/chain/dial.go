@@ -0,0 +1,26 @@
+package chain
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+	"strings"
+)
+
+// Dial connects to the JSON-RPC node at url. Calls over HTTP are traced and carry the ID of the request they're
+// made for
+func Dial(ctx context.Context, url string) (*rpc.Client, error) {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		transport := otelhttp.NewTransport(&logging.Transport{}, otelhttp.WithSpanNameFormatter(spanName))
+		return rpc.DialHTTPWithClient(url, &http.Client{Transport: transport})
+	}
+
+	return rpc.DialContext(ctx, url)
+}
+
+// spanName hides the path of the node url, providers put their API key in it
+func spanName(_ string, req *http.Request) string {
+	return "rpc " + req.URL.Host
+}
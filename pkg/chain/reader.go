@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"math"
+	"math/big"
+)
+
+// balanceOfSelector is the function selector of balanceOf(address), shared by ERC-20 and ERC-721
+var balanceOfSelector = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+
+// RPCReader reads contract state from a JSON-RPC node with eth_call
+type RPCReader struct {
+	client *ethclient.Client
+}
+
+// NewRPCReader connects to the JSON-RPC node at url
+func NewRPCReader(ctx context.Context, url string) (*RPCReader, error) {
+	client, err := Dial(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial rpc node: %w", err)
+	}
+
+	return &RPCReader{ethclient.NewClient(client)}, nil
+}
+
+// BalanceOf returns the number of tokens of contract held by owner at the latest block. Balances that don't fit
+// in an uint64 are capped
+func (r *RPCReader) BalanceOf(ctx context.Context, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (balance uint64, err error) {
+	ctx, span := tracing.Start(ctx, "RPCReader.BalanceOf", trace.WithAttributes(
+		attribute.String("chain.contract", contractAddress.Hex()),
+		attribute.String("chain.owner", ownerAddress.Hex()),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	contract := common.Address(contractAddress)
+	owner := common.Address(ownerAddress)
+
+	data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(owner.Bytes(), 32)...)
+
+	result, err := r.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to call balanceOf on %s: %w", contract.Hex(), err)
+	}
+	if len(result) != 32 {
+		return 0, fmt.Errorf("unexpected balanceOf result from %s: %x", contract.Hex(), result)
+	}
+
+	value := new(big.Int).SetBytes(result)
+	if !value.IsUint64() {
+		return math.MaxUint64, nil
+	}
+
+	return value.Uint64(), nil
+}
+
+// Close closes the underlying rpc connection
+func (r *RPCReader) Close() {
+	r.client.Close()
+}
@@ -0,0 +1,44 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+)
+
+// Readers dispatches reads to the reader of each chain
+type Readers map[domain.ChainID]*RPCReader
+
+// DialReaders connects to the node of every chain in urls
+func DialReaders(ctx context.Context, urls URLs) (Readers, error) {
+	readers := Readers{}
+
+	for chainID, url := range urls {
+		reader, err := NewRPCReader(ctx, url)
+		if err != nil {
+			readers.Close()
+			return nil, fmt.Errorf("chain %d: %w", chainID, err)
+		}
+
+		readers[chainID] = reader
+	}
+
+	return readers, nil
+}
+
+// BalanceOf returns the number of tokens of contract held by owner on chainID
+func (r Readers) BalanceOf(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error) {
+	reader, ok := r[chainID]
+	if !ok {
+		return 0, fmt.Errorf("no rpc node configured for chain %d", chainID)
+	}
+
+	return reader.BalanceOf(ctx, contractAddress, ownerAddress)
+}
+
+// Close closes the connections of every reader
+func (r Readers) Close() {
+	for _, reader := range r {
+		reader.Close()
+	}
+}
@@ -0,0 +1,42 @@
+package chain
+
+import (
+	"fmt"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"strconv"
+	"strings"
+)
+
+// URLs are the JSON-RPC node urls of each chain. It is read from a comma separated list of chainID=url pairs, e.g.
+// "1=https://mainnet.example,42161=https://arbitrum.example"
+type URLs map[domain.ChainID]string
+
+func (u *URLs) UnmarshalText(text []byte) error {
+	urls := URLs{}
+
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("invalid chain url %q, expected chainID=url", pair)
+		}
+
+		chainID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chain id %q: %w", parts[0], err)
+		}
+		if err = domain.ValidateChainID(domain.ChainID(chainID)); err != nil {
+			return fmt.Errorf("invalid chain id %q", parts[0])
+		}
+
+		urls[domain.ChainID(chainID)] = parts[1]
+	}
+
+	*u = urls
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package chain
+
+import (
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestURLs_UnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	var urls URLs
+	require.NoError(t, urls.UnmarshalText([]byte("1=https://mainnet.example, 42161=https://arbitrum.example/?key=a=b")))
+	assert.Equal(t, URLs{
+		domain.ChainIDMainnet: "https://mainnet.example",
+		42161:                 "https://arbitrum.example/?key=a=b",
+	}, urls)
+
+	require.NoError(t, urls.UnmarshalText([]byte("")))
+	assert.Empty(t, urls)
+
+	assert.Error(t, urls.UnmarshalText([]byte("https://mainnet.example")))
+	assert.Error(t, urls.UnmarshalText([]byte("mainnet=https://mainnet.example")))
+	assert.Error(t, urls.UnmarshalText([]byte("0=https://mainnet.example")))
+}
@@ -3,10 +3,12 @@ package controller
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
 	"github.com/manta-coder/golang-serverless-example/pkg/service"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
 )
 
 type AuthController struct {
@@ -27,9 +29,14 @@ func NewAuthController(e *echo.Group, logger *zap.SugaredLogger, authService ser
 func (ctrl *AuthController) Challenge(c echo.Context) error {
 	addressHex := c.FormValue("ethereum_address")
 
-	input := auth.NewChallengeInput(addressHex)
+	chainID, err := chainIDFormValue(c)
+	if err != nil {
+		return err
+	}
 
-	response, err := ctrl.authService.Challenge(input)
+	input := auth.NewChallengeInput(addressHex, chainID)
+
+	response, err := ctrl.authService.Challenge(c.Request().Context(), input)
 	if err != nil {
 		return httperror.FromDomain(err)
 	}
@@ -41,9 +48,14 @@ func (ctrl *AuthController) Authorize(c echo.Context) error {
 	addressHex := c.FormValue("ethereum_address")
 	sigHex := c.FormValue("signature")
 
-	input := auth.NewAuthorizeInput(addressHex, sigHex)
+	chainID, err := chainIDFormValue(c)
+	if err != nil {
+		return err
+	}
+
+	input := auth.NewAuthorizeInput(addressHex, chainID, sigHex)
 
-	response, err := ctrl.authService.Authorize(input)
+	response, err := ctrl.authService.Authorize(c.Request().Context(), input)
 	if err != nil {
 		return httperror.FromDomain(err)
 	}
@@ -54,12 +66,27 @@ func (ctrl *AuthController) Authorize(c echo.Context) error {
 func (ctrl *AuthController) AuthorizeSilently(c echo.Context) error {
 	claims := getClaims(c)
 
-	input := auth.NewAuthorizeSilentlyInput(claims.EthereumAddressHex)
+	input := auth.NewAuthorizeSilentlyInput(claims.EthereumAddress.Hex(), claims.Chain())
 
-	response, err := ctrl.authService.AuthorizeSilently(input)
+	response, err := ctrl.authService.AuthorizeSilently(c.Request().Context(), input)
 	if err != nil {
 		return httperror.FromDomain(err)
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+// chainIDFormValue returns the optional chain_id form value or 0 if it's missing
+func chainIDFormValue(c echo.Context) (domain.ChainID, error) {
+	value := c.FormValue("chain_id")
+	if value == "" {
+		return 0, nil
+	}
+
+	chainID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, httperror.CoreRequestStringConversionFailed(err)
+	}
+
+	return domain.ChainID(chainID), nil
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"net/http"
+	"strconv"
+)
+
+// ErrorsController serves the catalog of error codes clients may receive
+type ErrorsController struct{}
+
+func NewErrorsController(e *echo.Group) {
+	ctrl := &ErrorsController{}
+	e.GET("", ctrl.List)
+	e.GET("/:code", ctrl.Get)
+}
+
+// List returns every registered error sorted by code
+func (ctrl *ErrorsController) List(c echo.Context) error {
+	return c.JSON(http.StatusOK, domain.Definitions())
+}
+
+// Get returns the error identified by code, it's the type of problem responses
+func (ctrl *ErrorsController) Get(c echo.Context) error {
+	code, err := strconv.Atoi(c.Param("code"))
+	if err != nil {
+		return httperror.CoreRequestStringConversionFailed(err)
+	}
+
+	def, ok := domain.Lookup(code)
+	if !ok {
+		return httperror.FromDomain(domain.ErrNotFound(nil))
+	}
+
+	return c.JSON(http.StatusOK, def)
+}
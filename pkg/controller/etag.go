@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"strconv"
+	"strings"
+)
+
+// ETag returns the strong entity tag of a resource version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version required by the If-Match header, or 0 when any version is accepted. Weak tags
+// never match since If-Match uses the strong comparison
+func ifMatchVersion(c echo.Context) (int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if strings.HasPrefix(header, "W/") {
+		return 0, httperror.FromDomain(domain.ErrPreconditionFailed(errors.New("weak entity tags never match")))
+	}
+
+	// a list of tags can't match either, a user has a single current version
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return 0, httperror.FromDomain(domain.ErrPreconditionFailed(errors.New("unknown entity tag " + header)))
+	}
+
+	return version, nil
+}
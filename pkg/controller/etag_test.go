@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	t.Parallel()
+
+	version := func(header string) (int, error) {
+		c, _ := tester.NewContext(tester.WithHeader("If-Match", header))
+		return ifMatchVersion(c)
+	}
+
+	v, err := version("")
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+
+	v, err = version("*")
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+
+	v, err = version(ETag(3))
+	require.NoError(t, err)
+	assert.Equal(t, 3, v)
+
+	// tags that can't be the current version fail the precondition
+	for _, header := range []string{`W/"3"`, "3", `"abc"`, `"3", "4"`} {
+		_, err = version(header)
+		require.Error(t, err, header)
+		assert.Equal(t, http.StatusPreconditionFailed, err.(*httperror.Error).StatusCode, header)
+	}
+}
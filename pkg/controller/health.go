@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"github.com/manta-coder/golang-serverless-example/pkg/version"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Check is a dependency an instance needs to serve requests
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// HealthResponse is the state of the instance, with the result of each check for readiness
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HealthController tells load balancers and deployments whether an instance is alive, ready and which build it runs
+type HealthController struct {
+	logger  *zap.SugaredLogger
+	info    version.Info
+	timeout time.Duration
+	checks  []Check
+}
+
+// NewHealthController serves /health, /ready and /version. Checks of /ready share timeout, 0 means none
+func NewHealthController(e *echo.Echo, logger *zap.SugaredLogger, info version.Info, timeout time.Duration, checks ...Check) {
+	ctrl := &HealthController{
+		logger:  logger,
+		info:    info,
+		timeout: timeout,
+		checks:  checks,
+	}
+	e.GET("/health", ctrl.Health)
+	e.GET("/ready", ctrl.Ready)
+	e.GET("/version", ctrl.Version)
+}
+
+// Health answers as long as the process serves requests, it checks nothing else
+func (ctrl *HealthController) Health(c echo.Context) error {
+	return c.JSON(http.StatusOK, HealthResponse{Status: StatusOK})
+}
+
+// Ready runs every check, it answers 503 when one fails. Failures are only detailed in the logs
+func (ctrl *HealthController) Ready(c echo.Context) error {
+	ctx := c.Request().Context()
+	if ctrl.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ctrl.timeout)
+		defer cancel()
+	}
+
+	response := HealthResponse{Status: StatusOK, Checks: make(map[string]string, len(ctrl.checks))}
+	status := http.StatusOK
+
+	for _, check := range ctrl.checks {
+		if err := check.Run(ctx); err != nil {
+			logging.FromContext(ctx, ctrl.logger).Warnw("readiness check failed", "check", check.Name, "err", err)
+
+			response.Checks[check.Name] = StatusFailed
+			response.Status = StatusFailed
+			status = http.StatusServiceUnavailable
+			continue
+		}
+
+		response.Checks[check.Name] = StatusOK
+	}
+
+	return c.JSON(status, response)
+}
+
+// Version returns the build and the environment of the instance
+func (ctrl *HealthController) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, ctrl.info)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveHealth(t *testing.T, e *echo.Echo, path string) (int, map[string]interface{}) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return rec.Code, body
+}
+
+func TestHealthController(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	info := version.Info{Version: "1.4.0", Commit: "3f2c1ab", BuildTime: "2026-10-19T12:00:00Z", Environment: "prd"}
+	NewHealthController(e, zap.NewNop().Sugar(), info, time.Second,
+		Check{Name: "database", Run: func(ctx context.Context) error { return nil }},
+	)
+
+	code, body := serveHealth(t, e, "/health")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"status": "ok"}, body)
+
+	code, body = serveHealth(t, e, "/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"status": "ok", "checks": map[string]interface{}{"database": "ok"}}, body)
+
+	code, body = serveHealth(t, e, "/version")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{
+		"version":     "1.4.0",
+		"commit":      "3f2c1ab",
+		"build_time":  "2026-10-19T12:00:00Z",
+		"environment": "prd",
+	}, body)
+}
+
+func TestHealthController_NotReady(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	NewHealthController(e, zap.NewNop().Sugar(), version.Get("dev"), 10*time.Millisecond,
+		Check{Name: "database", Run: func(ctx context.Context) error {
+			// a database that doesn't answer is cut off by the timeout
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		Check{Name: "migrations", Run: func(ctx context.Context) error {
+			return errors.New("schema version 20261019160000 is behind 20261019170000 at 10.0.0.12")
+		}},
+	)
+
+	start := time.Now()
+	code, body := serveHealth(t, e, "/ready")
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	// the causes stay in the logs
+	assert.Equal(t, map[string]interface{}{
+		"status": "failed",
+		"checks": map[string]interface{}{"database": "failed", "migrations": "failed"},
+	}, body)
+
+	// the instance is still alive
+	code, _ = serveHealth(t, e, "/health")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/service"
+	"net/http"
+)
+
+// NewMembershipController mounts GET /me/membership, clients call it to know if the user may use the features gated
+// behind holding minBalance tokens of contract. The group must be authenticated
+func NewMembershipController(e *echo.Group, holdingService service.HoldingService, contractAddress domain.EthereumAddress, minBalance uint64) {
+	e.GET("/me/membership", membership, WithHoldingService(holdingService), RequireTokenHolding(contractAddress, minBalance))
+}
+
+// membership answers once RequireTokenHolding let the user through
+func membership(c echo.Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
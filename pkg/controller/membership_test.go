@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMembershipController(t *testing.T) {
+	t.Parallel()
+
+	const secret = "123456789abcdefghijklmnopqrstuvwyz"
+
+	holder := domain.EthereumAddress(tester.GenerateAddress(t))
+	other := domain.EthereumAddress(tester.GenerateAddress(t))
+	contract := domain.EthereumAddress(tester.GenerateAddress(t))
+
+	holdingService := &fakeHoldingService{balances: map[string]uint64{
+		domain.NewWallet(domain.ChainIDMainnet, holder).String(): 1,
+	}}
+
+	e := server.NewEcho(tester.GetLogger(), server.DefaultLogConfig, server.NopMetrics, server.DefaultCORSConfig)
+	NewMembershipController(e.Group("/users", NewAuthenticator(secret)), holdingService, contract, 1)
+
+	authentication := auth.NewService(secret, time.Minute, domain.ChainIDMainnet)
+	serve := func(address domain.EthereumAddress) int {
+		token, err := authentication.IssueToken(domain.User{UserID: "usr_1", EthereumAddress: address}, domain.ChainIDMainnet)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/users/me/membership", nil)
+		req.Header.Set("Authorization", "Bearer "+string(token))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(holder))
+	assert.Equal(t, http.StatusForbidden, serve(other))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/me/membership", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -1,12 +1,18 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"github.com/manta-coder/golang-serverless-example/pkg/i18n"
+	"github.com/manta-coder/golang-serverless-example/pkg/service"
+	"sync"
+	"time"
 )
 
 func NewAuthenticator(secret string) echo.MiddlewareFunc {
@@ -16,6 +22,10 @@ func NewAuthenticator(secret string) echo.MiddlewareFunc {
 		ErrorHandler: func(err error) error {
 			return httperror.CoreUnauthorized(err)
 		},
+		// errors are written in the locale the user prefers
+		SuccessHandler: func(c echo.Context) {
+			c.Set(i18n.ContextKey, getClaims(c).Locale)
+		},
 	})
 }
 
@@ -23,7 +33,10 @@ func NewAuthMiddleware() echo.MiddlewareFunc {
 	return func(h echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			claims := getClaims(c)
-			if c.QueryParam("ethereum_address") != claims.EthereumAddressHex {
+
+			// the query may use another casing than the token
+			address, err := domain.ParseEthereumAddress(c.QueryParam("ethereum_address"))
+			if err != nil || address != claims.EthereumAddress {
 				return httperror.CoreUnauthorized(fmt.Errorf("invalid address"))
 			}
 			return h(c)
@@ -31,7 +44,127 @@ func NewAuthMiddleware() echo.MiddlewareFunc {
 	}
 }
 
+const (
+	// insufficientHoldingTTL is how long a failed holding check is cached, users who just bought a token are let in soon
+	insufficientHoldingTTL = 30 * time.Second
+	// sessionCacheSweepInterval is the minimum time between two sweeps of the expired sessions
+	sessionCacheSweepInterval = time.Minute
+)
+
+// holdingServiceKey is the context key of the service used by RequireTokenHolding
+const holdingServiceKey = "HoldingService"
+
+// WithHoldingService makes holdingService available to the RequireTokenHolding middlewares registered after it
+func WithHoldingService(holdingService service.HoldingService) echo.MiddlewareFunc {
+	return func(h echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(holdingServiceKey, holdingService)
+			return h(c)
+		}
+	}
+}
+
+// RequireTokenHolding only lets through users holding at least minBalance tokens of contract on the chain they signed
+// in with. Must be registered after the authenticator and WithHoldingService. A holding is cached until the token of
+// the user expires, a missing holding for insufficientHoldingTTL
+func RequireTokenHolding(contractAddress domain.EthereumAddress, minBalance uint64) echo.MiddlewareFunc {
+	cache := newSessionCache()
+
+	return func(h echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			holdingService, ok := c.Get(holdingServiceKey).(service.HoldingService)
+			if !ok {
+				return errors.New("missing holding service")
+			}
+
+			token, ok := c.Get("user").(*jwt.Token)
+			if !ok {
+				return httperror.CoreUnauthorized(errors.New("missing token"))
+			}
+			claims := token.Claims.(*auth.Claims)
+
+			holds, ok := cache.get(token.Raw)
+			if !ok {
+				var err error
+
+				holds, err = holdingService.HoldsToken(c.Request().Context(), claims.Wallet(), contractAddress, minBalance)
+				if err != nil {
+					return httperror.FromDomain(err)
+				}
+
+				expiresAt := time.Unix(claims.ExpiresAt, 0)
+				if !holds && time.Now().Add(insufficientHoldingTTL).Before(expiresAt) {
+					expiresAt = time.Now().Add(insufficientHoldingTTL)
+				}
+
+				cache.set(token.Raw, holds, expiresAt)
+			}
+
+			if !holds {
+				return httperror.FromDomain(domain.ErrInsufficientTokenHolding(nil))
+			}
+
+			return h(c)
+		}
+	}
+}
+
+type sessionCacheEntry struct {
+	value     bool
+	expiresAt time.Time
+}
+
+// sessionCache keeps a decision per session token until it expires
+type sessionCache struct {
+	mu      sync.Mutex
+	entries map[string]sessionCacheEntry
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{entries: map[string]sessionCacheEntry{}, now: time.Now}
+}
+
+func (c *sessionCache) get(key string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return false, false
+	}
+	if c.now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return false, false
+	}
+
+	return entry.value, true
+}
+
+func (c *sessionCache) set(key string, value bool, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// sessions that are never used again aren't evicted by get, they are dropped by a sweep from time to time so
+	// the cache doesn't grow for the lifetime of the process
+	now := c.now()
+	if now.Sub(c.sweptAt) >= sessionCacheSweepInterval {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweptAt = now
+	}
+
+	c.entries[key] = sessionCacheEntry{value, expiresAt}
+}
+
+func getToken(c echo.Context) *jwt.Token {
+	return c.Get("user").(*jwt.Token)
+}
+
 func getClaims(c echo.Context) *auth.Claims {
-	user := c.Get("user").(*jwt.Token)
-	return user.Claims.(*auth.Claims)
+	return getToken(c).Claims.(*auth.Claims)
 }
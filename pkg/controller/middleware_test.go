@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeHoldingService struct {
+	balances map[string]uint64
+	calls    int
+}
+
+func (s *fakeHoldingService) HoldsToken(ctx context.Context, wallet domain.Wallet, contractAddress domain.EthereumAddress, minBalance uint64) (bool, error) {
+	s.calls++
+	return s.balances[wallet.String()] >= minBalance, nil
+}
+
+func withTestToken(t *testing.T, address domain.EthereumAddress, chainID domain.ChainID) tester.ContextOptions {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+		EthereumAddress: address,
+		ChainID:         chainID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	})
+	token.Raw = fmt.Sprintf("%s:%d", address.Hex(), chainID)
+
+	return tester.WithData("user", token)
+}
+
+func TestRequireTokenHolding(t *testing.T) {
+	t.Parallel()
+
+	holder := domain.EthereumAddress(tester.GenerateAddress(t))
+	other := domain.EthereumAddress(tester.GenerateAddress(t))
+	contract := domain.EthereumAddress(tester.GenerateAddress(t))
+
+	holdingService := &fakeHoldingService{balances: map[string]uint64{
+		domain.NewWallet(domain.ChainIDMainnet, holder).String(): 2,
+	}}
+	mw := WithHoldingService(holdingService)(RequireTokenHolding(contract, 2)(tester.NopHandlerFunc))
+
+	// tokens issued before multi-chain support have no chain and default to mainnet
+	c, _ := tester.NewContext(withTestToken(t, holder, 0))
+	require.NoError(t, mw(c))
+
+	// the decision is cached for the session
+	c, _ = tester.NewContext(withTestToken(t, holder, 0))
+	require.NoError(t, mw(c))
+	assert.Equal(t, 1, holdingService.calls)
+
+	c, _ = tester.NewContext(withTestToken(t, holder, domain.ChainIDMainnet))
+	require.NoError(t, mw(c))
+
+	// holding on mainnet doesn't count on another chain
+	c, _ = tester.NewContext(withTestToken(t, holder, 42161))
+	err := mw(c)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, httperror.FromDomain(domain.ErrInsufficientTokenHolding(nil)))
+
+	c, _ = tester.NewContext(withTestToken(t, other, domain.ChainIDMainnet))
+	err = mw(c)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, httperror.FromDomain(domain.ErrInsufficientTokenHolding(nil)))
+	assert.Equal(t, 4, holdingService.calls)
+
+	// routes mounted without the authenticator are refused instead of panicking
+	c, _ = tester.NewContext()
+	err = mw(c)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, httperror.CoreUnauthorized(nil))
+
+	// and so are routes mounted without a holding service
+	c, _ = tester.NewContext(withTestToken(t, holder, domain.ChainIDMainnet))
+	require.Error(t, RequireTokenHolding(contract, 2)(tester.NopHandlerFunc)(c))
+}
+
+func TestSessionCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cache := newSessionCache()
+	cache.now = func() time.Time { return now }
+
+	cache.set("holder", true, now.Add(time.Hour))
+	cache.set("other", false, now.Add(insufficientHoldingTTL))
+
+	holds, ok := cache.get("other")
+	assert.True(t, ok)
+	assert.False(t, holds)
+
+	// expired entries are evicted when read
+	now = now.Add(insufficientHoldingTTL + time.Second)
+	_, ok = cache.get("other")
+	assert.False(t, ok)
+	assert.NotContains(t, cache.entries, "other")
+
+	// and by the next sweep when they're never read again
+	cache.set("expired", true, now.Add(time.Second))
+	now = now.Add(sessionCacheSweepInterval)
+	cache.set("new", true, now.Add(time.Hour))
+	assert.NotContains(t, cache.entries, "expired")
+
+	holds, ok = cache.get("holder")
+	assert.True(t, ok)
+	assert.True(t, holds)
+}
@@ -1,11 +1,14 @@
 package controller
 
 import (
+	"encoding/json"
 	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
 	"github.com/manta-coder/golang-serverless-example/pkg/service"
 	"go.uber.org/zap"
 	"net/http"
+	"strings"
 )
 
 type UserController struct {
@@ -19,15 +22,93 @@ func NewUserController(e *echo.Group, logger *zap.SugaredLogger, userService ser
 		userService: userService,
 	}
 	e.GET("/hello", ctrl.Hello)
+	e.GET("/find", ctrl.Find)
+	e.GET("/me", ctrl.Me)
+	e.PUT("/me", ctrl.UpdateMe)
 }
 
 func (ctrl *UserController) Hello(c echo.Context) error {
 	claims := getClaims(c)
 
-	response, err := ctrl.userService.Get(claims.UserID)
+	response, err := ctrl.userService.Get(c.Request().Context(), claims.UserID)
 	if err != nil {
 		return httperror.FromDomain(err)
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+// Find returns the user identified by an ENS name (name.eth) or an ethereum address
+func (ctrl *UserController) Find(c echo.Context) error {
+	identifier := c.QueryParam("identifier")
+
+	response, err := ctrl.userService.Find(c.Request().Context(), identifier)
+	if err != nil {
+		return httperror.FromDomain(err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Me returns the authenticated user with its version as ETag
+func (ctrl *UserController) Me(c echo.Context) error {
+	claims := getClaims(c)
+
+	response, err := ctrl.userService.Get(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return httperror.FromDomain(err)
+	}
+
+	c.Response().Header().Set("ETag", ETag(response.Version))
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// UpdateMe updates the fields the client sent, as a form or JSON, the others keep their value. Sending the ETag of Me
+// as If-Match makes the update fail with 412 when the user changed in between instead of overwriting the change
+func (ctrl *UserController) UpdateMe(c echo.Context) error {
+	claims := getClaims(c)
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	input, err := userUpdateInput(c, domain.NewUserUpdateInput(claims.UserID, version))
+	if err != nil {
+		return err
+	}
+
+	response, err := ctrl.userService.Update(c.Request().Context(), input)
+	if err != nil {
+		return httperror.FromDomain(err)
+	}
+
+	c.Response().Header().Set("ETag", ETag(response.Version))
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// userUpdateInput sets the fields of input present in the JSON or form body
+func userUpdateInput(c echo.Context, input domain.UserUpdateInput) (domain.UserUpdateInput, error) {
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if err := json.NewDecoder(c.Request().Body).Decode(&input); err != nil {
+			return input, httperror.CoreRequestBindingFailed(err)
+		}
+
+		return input, nil
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return input, httperror.CoreRequestBindingFailed(err)
+	}
+	if _, ok := form["username"]; ok {
+		input = input.WithUsername(form.Get("username"))
+	}
+	if _, ok := form["locale"]; ok {
+		input = input.WithLocale(form.Get("locale"))
+	}
+
+	return input, nil
+}
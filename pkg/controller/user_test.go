@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserUpdateInput(t *testing.T) {
+	t.Parallel()
+
+	parse := func(contentType string, body string) domain.UserUpdateInput {
+		req := httptest.NewRequest(http.MethodPut, "/users/me", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		input, err := userUpdateInput(c, domain.NewUserUpdateInput("usr_1", 3))
+		require.NoError(t, err)
+
+		return input
+	}
+
+	// only the fields sent are set
+	input := parse(echo.MIMEApplicationJSON, `{"locale":"ja"}`)
+	assert.Equal(t, "ja", *input.Locale)
+	assert.Nil(t, input.Username)
+
+	input = parse(echo.MIMEApplicationForm, "username=renamed")
+	assert.Equal(t, "renamed", *input.Username)
+	assert.Nil(t, input.Locale)
+
+	// an empty value is sent, it's not left out
+	input = parse(echo.MIMEApplicationForm, "locale=")
+	assert.Equal(t, "", *input.Locale)
+
+	// the user and version come from the token and If-Match, not the body
+	input = parse(echo.MIMEApplicationJSON, `{"UserID":"usr_2","Version":1}`)
+	assert.Equal(t, "usr_1", input.UserID)
+	assert.Equal(t, 3, input.Version)
+}
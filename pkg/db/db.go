@@ -3,13 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/log/zapadapter"
-	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
-	"golang.org/x/crypto/ssh"
-	"net"
 	"net/url"
 	"reflect"
 	"time"
@@ -45,12 +39,13 @@ func JoinSuffix(table1, table2, column string) string {
 	return table1 + " ON " + table1 + "." + column + " = " + table2 + "." + column
 }
 
+// QueryExecuteError wraps a query failure in a QueryError classified by Classify
 func QueryExecuteError(err error, query string, args []interface{}) error {
 	if len(query) > 200 {
 		query = query[:200] + "..."
 	}
 
-	return fmt.Errorf("failed to execute query: %w\n%s\n%s", err, query, args)
+	return &QueryError{Kind: Classify(err), Query: query, Args: args, Err: err}
 }
 
 func UnmarshalError(err error, query string, args []interface{}) error {
@@ -66,82 +61,57 @@ func PostgresURL(host, port, user, pass, database string) string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s", user, pass, host, port, database)
 }
 
-// Postgres creates a database connection with pgx and sqlx
-func Postgres(host, port, user, pass, database string, clients ...*ssh.Client) (*sqlx.DB, error) {
-	u := PostgresURL(host, port, user, pass, database)
-
-	config, err := pgx.ParseConfig(u)
-	if err != nil {
-		return nil, fmt.Errorf("invalid url %s: %w", u, err)
+// Postgres creates a database connection with pgx and sqlx and waits until the database answers
+func Postgres(host, port, user, pass, database string, tunnels ...*Tunnel) (*sqlx.DB, error) {
+	config := Config{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Pass:     pass,
+		Database: database,
+		Pool:     PoolConfig{MaxIdleConns: 2},
+	}
+	if len(tunnels) > 0 {
+		config.Tunnel = tunnels[0]
 	}
 
-	// assumes the global logger is already configured correctly
-	config.Logger = zapadapter.NewLogger(zap.L())
-
-	// dialer is used to transmit data via SSH
-	if len(clients) > 0 {
-		client := clients[0]
-
-		dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return client.Dial(network, addr)
-		}
-
-		config.DialFunc = dialer
+	db, err := Open(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// open connection
-	db := stdlib.OpenDB(*config)
-	db.SetMaxIdleConns(0)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
 	defer cancel()
 
 	// make sure connection is successful
-	err = db.PingContext(ctx)
-
-	if err != nil {
-		return nil, fmt.Errorf("unable to ping db %s: %w", u, err)
+	if err = db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("unable to ping db %s: %w", PostgresURL(host, port, user, pass, database), err)
 	}
 
-	return sqlx.NewDb(db, "pgx"), nil
+	return db, nil
 }
 
+// WithRetry calls fn until it succeeds, waiting period between attempts, at most limit times
 func WithRetry(period time.Duration, limit int, fn func() (*sqlx.DB, error)) (*sqlx.DB, error) {
 	var db *sqlx.DB
-	var err error
 
-	for i := 0; i < limit; i++ {
-		if db, err = fn(); err == nil {
-			return db, nil
-		}
-		<-time.After(period)
+	if limit < 1 {
+		limit = 1
 	}
 
-	return nil, fmt.Errorf("retry failed: %w", err)
-}
-
-func SSH(sshUser, sshHost, sshPort string, sshKey []byte) (*ssh.Client, error) {
-	key, err := ssh.ParsePrivateKey(sshKey)
-
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse private key: %w", err)
-	}
-
-	var auth ssh.AuthMethod
-	auth = ssh.PublicKeys(key)
-
-	cfg := &ssh.ClientConfig{
-		User:            sshUser,
-		Auth:            []ssh.AuthMethod{auth},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", sshHost, sshPort), cfg)
+	constant := Backoff{Initial: period, Max: period, Multiplier: 1, MaxAttempts: limit}
+	always := func(err error) bool { return true }
 
+	err := Retry(context.Background(), constant, always, func(ctx context.Context) error {
+		var err error
+		db, err = fn()
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to open ssh connection: %w", err)
+		return nil, fmt.Errorf("retry failed: %w", err)
 	}
 
-	return client, nil
+	return db, nil
 }
 
 // GetDBColumns returns all the field defined by `db` tags of a struct
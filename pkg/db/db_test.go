@@ -8,13 +8,14 @@ import (
 )
 
 var pem = flag.String("pem", "", "PEM key location")
+var knownHosts = flag.String("known-hosts", "", "known_hosts file holding the key of the bastion")
 var postgresPass = flag.String("postgres-pass", "", "Database password")
 
 // 10.0.8.145 is the private IP of DNS: postgres.staging.darwinrevolution.com. This DNS is only available from within the VPC.
 var host = flag.String("host", "10.0.8.145", "Private IP of the database. If using a hostname, the hostname must resolve from the host's PC")
 
 func TestPostgresSSH(t *testing.T) {
-	// to run this test: go test ./pkg/db -run TestPostgresSSH -pem "/path/to/bastion.pem"
+	// to run this test: go test ./pkg/db -run TestPostgresSSH -pem "/path/to/bastion.pem" -known-hosts ~/.ssh/known_hosts
 	if *pem == "" {
 		t.Skip()
 	}
@@ -30,9 +31,19 @@ func TestPostgresSSH(t *testing.T) {
 	port := "5432"
 	bastion := "54.198.236.124"
 
-	client, err := SSH("ec2-user", bastion, "22", key)
+	tunnel, err := NewTunnel(SSHConfig{
+		User:           "ec2-user",
+		Host:           bastion,
+		Port:           "22",
+		Key:            key,
+		KnownHostsFile: *knownHosts,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer tunnel.Close()
 
-	_, err = Postgres(*host, port, user, *postgresPass, database, client)
+	_, err = Postgres(*host, port, user, *postgresPass, database, tunnel)
 
 	assert.NoError(t, err)
 }
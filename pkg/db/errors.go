@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+)
+
+// Kinds of query failures callers can react to, match them with errors.Is
+var (
+	ErrNotFound       = errors.New("no rows in result set")
+	ErrConflict       = errors.New("conflict")
+	ErrForeignKey     = errors.New("foreign key constraint violation")
+	ErrCheckViolation = errors.New("check constraint violation")
+	ErrTimeout        = errors.New("query timed out")
+	// ErrVersionMismatch is the conflict of a write expecting another version of the row
+	ErrVersionMismatch = fmt.Errorf("%w: row was modified since it was read", ErrConflict)
+)
+
+// kindsByCode maps SQLSTATE codes to kinds
+var kindsByCode = map[string]error{
+	"23505": ErrConflict,       // unique_violation
+	"23P01": ErrConflict,       // exclusion_violation
+	"23503": ErrForeignKey,     // foreign_key_violation
+	"23514": ErrCheckViolation, // check_violation
+	"23502": ErrCheckViolation, // not_null_violation
+	"57014": ErrTimeout,        // query_canceled, raised by statement_timeout
+	"55P03": ErrTimeout,        // lock_not_available, raised by lock_timeout
+}
+
+// Classify returns the kind of a query failure or nil if it isn't one of them
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return kindsByCode[pgErr.Code]
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return ErrTimeout
+	}
+
+	return nil
+}
+
+// QueryError is a failed query. errors.Is matches its kind as well as the driver error it wraps
+type QueryError struct {
+	Kind  error
+	Query string
+	Args  []interface{}
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("failed to execute query: %s\n%s\n%s", e.Err, e.Query, e.Args)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *QueryError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Constraint returns the name of the violated constraint, if any
+func (e *QueryError) Constraint() string {
+	var pgErr *pgconn.PgError
+	if errors.As(e.Err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+
+	return ""
+}
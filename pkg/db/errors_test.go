@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, Classify(nil))
+	assert.Equal(t, ErrNotFound, Classify(sql.ErrNoRows))
+	assert.Equal(t, ErrConflict, Classify(&pgconn.PgError{Code: "23505"}))
+	assert.Equal(t, ErrForeignKey, Classify(&pgconn.PgError{Code: "23503"}))
+	assert.Equal(t, ErrCheckViolation, Classify(&pgconn.PgError{Code: "23514"}))
+	assert.Equal(t, ErrTimeout, Classify(&pgconn.PgError{Code: "57014"}))
+	assert.Equal(t, ErrTimeout, Classify(context.DeadlineExceeded))
+	assert.Nil(t, Classify(&pgconn.PgError{Code: "42601"}))
+	assert.Nil(t, Classify(errors.New("unknown")))
+}
+
+func TestQueryExecuteError(t *testing.T) {
+	t.Parallel()
+
+	pgErr := &pgconn.PgError{Code: "23505", ConstraintName: "users_ethereum_address_key"}
+	err := QueryExecuteError(pgErr, "INSERT INTO users", []interface{}{"0x"})
+
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	// the driver error stays reachable
+	var target *pgconn.PgError
+	require.True(t, errors.As(err, &target))
+	assert.Equal(t, pgErr, target)
+
+	var queryErr *QueryError
+	require.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "users_ethereum_address_key", queryErr.Constraint())
+
+	err = QueryExecuteError(sql.ErrNoRows, "SELECT 1", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"github.com/jackc/pgx/v4"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// IAMTokenLifetime is how long RDS accepts an IAM auth token
+	IAMTokenLifetime = 15 * time.Minute
+	// IAMTokenRefreshBefore is how long before expiry a token is replaced, so a connection never starts with a token
+	// about to expire
+	IAMTokenRefreshBefore = 5 * time.Minute
+)
+
+// TokenSigner creates IAM auth tokens, which are used as password
+type TokenSigner interface {
+	Sign(ctx context.Context) (string, error)
+}
+
+// RDSTokenSigner signs tokens for a database user with AWS credentials
+type RDSTokenSigner struct {
+	endpoint string
+	region   string
+	user     string
+	creds    *credentials.Credentials
+}
+
+func NewRDSTokenSigner(host, port, region, user string, creds *credentials.Credentials) *RDSTokenSigner {
+	return &RDSTokenSigner{net.JoinHostPort(host, port), region, user, creds}
+}
+
+func (s *RDSTokenSigner) Sign(ctx context.Context) (string, error) {
+	token, err := rdsutils.BuildAuthToken(s.endpoint, s.region, s.user, s.creds)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign rds auth token: %w", err)
+	}
+
+	return token, nil
+}
+
+// TokenProvider reuses the token of a signer until it's about to expire
+type TokenProvider struct {
+	signer TokenSigner
+	now    func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewTokenProvider(signer TokenSigner) *TokenProvider {
+	return &TokenProvider{signer: signer, now: time.Now}
+}
+
+// Token returns a token valid for at least IAMTokenRefreshBefore
+func (p *TokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.token != "" && now.Before(p.expiresAt.Add(-IAMTokenRefreshBefore)) {
+		return p.token, nil
+	}
+
+	token, err := p.signer.Sign(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p.token = token
+	p.expiresAt = now.Add(IAMTokenLifetime)
+
+	return p.token, nil
+}
+
+// BeforeConnect sets a valid token as the password of every new connection
+func (p *TokenProvider) BeforeConnect(ctx context.Context, config *pgx.ConnConfig) error {
+	token, err := p.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	config.Password = token
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeTokenSigner struct {
+	signed int
+	err    error
+}
+
+func (s *fakeTokenSigner) Sign(ctx context.Context) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+
+	s.signed++
+
+	return fmt.Sprintf("token-%d", s.signed), nil
+}
+
+func TestTokenProvider_Token(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	signer := &fakeTokenSigner{}
+	provider := NewTokenProvider(signer)
+	provider.now = func() time.Time { return now }
+
+	token, err := provider.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// reused while it's far from expiring
+	now = now.Add(IAMTokenLifetime - IAMTokenRefreshBefore - time.Second)
+	token, err = provider.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// renewed before it expires
+	now = now.Add(2 * time.Second)
+	token, err = provider.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+
+	// a failed renewal doesn't hand out the old token
+	signer.err = errors.New("no credentials")
+	now = now.Add(IAMTokenLifetime)
+	_, err = provider.Token(ctx)
+	assert.ErrorIs(t, err, signer.err)
+}
+
+func TestTokenProvider_BeforeConnect(t *testing.T) {
+	t.Parallel()
+
+	provider := NewTokenProvider(&fakeTokenSigner{})
+
+	config, err := pgx.ParseConfig(PostgresURL("localhost", "5432", "odin", "", "odin"))
+	require.NoError(t, err)
+
+	require.NoError(t, provider.BeforeConnect(context.Background(), config))
+	assert.Equal(t, "token-1", config.Password)
+}
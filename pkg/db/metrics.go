@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"time"
+)
+
+// metricsLogger measures the statements pgx logs, then passes the entry on
+type metricsLogger struct {
+	metrics server.Metrics
+	next    pgx.Logger
+}
+
+// NewMetricsLogger returns a pgx logger measuring statements before logging them with next
+func NewMetricsLogger(metrics server.Metrics, next pgx.Logger) pgx.Logger {
+	return &metricsLogger{metrics, next}
+}
+
+func (l *metricsLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if queryMessages[msg] {
+		if _, failed := data["err"]; failed {
+			l.metrics.Add(server.MetricDBQueryErrors, 1, msg)
+		} else if elapsed, ok := data["time"].(time.Duration); ok {
+			l.metrics.Observe(server.MetricDBQueryDuration, elapsed.Seconds(), msg)
+		}
+	}
+
+	if l.next != nil {
+		l.next.Log(ctx, level, msg, data)
+	}
+}
+
+// PoolCollector samples the stats of the pool of db
+func PoolCollector(db *sql.DB) func(server.Metrics) {
+	return func(metrics server.Metrics) {
+		stats := db.Stats()
+
+		metrics.Set(server.MetricDBConnections, float64(stats.InUse), "in_use")
+		metrics.Set(server.MetricDBConnections, float64(stats.Idle), "idle")
+		metrics.Set(server.MetricDBConnections, float64(stats.MaxOpenConnections), "max_open")
+		metrics.Set(server.MetricDBConnectionWaits, float64(stats.WaitCount))
+		metrics.Set(server.MetricDBConnectionWaitDuration, stats.WaitDuration.Seconds())
+	}
+}
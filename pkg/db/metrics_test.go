@@ -0,0 +1,45 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMetricsLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	metrics := server.NewEMFMetrics("test", &buf)
+	next := &recordingLogger{}
+	logger := NewMetricsLogger(metrics, next)
+
+	logger.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql":  "SELECT * FROM users WHERE user_id = $1",
+		"time": 40 * time.Millisecond,
+	})
+	logger.Log(context.Background(), pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql":  "DELETE FROM users",
+		"time": 10 * time.Millisecond,
+		"err":  errors.New("permission denied"),
+	})
+	logger.Log(context.Background(), pgx.LogLevelInfo, "closed connection", nil)
+
+	// every entry is still logged
+	assert.Equal(t, []string{"Query", "Exec", "closed connection"}, next.messages)
+
+	require.NoError(t, metrics.Flush())
+
+	output := buf.String()
+	assert.Contains(t, output, `"db_query_duration_seconds":[0.04]`)
+	// failed statements are counted, not timed
+	assert.Contains(t, output, `"db_query_errors_total":1`)
+	assert.NotContains(t, output, "0.01")
+	assert.NotContains(t, output, "closed connection")
+}
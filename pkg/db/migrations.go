@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaVersion returns the version of the last migration applied to the database, dirty when it failed halfway
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (version uint64, dirty bool, err error) {
+	row := db.QueryRowxContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	if err = row.Scan(&version, &dirty); err != nil {
+		return 0, false, fmt.Errorf("unable to read schema version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// CheckSchema fails unless every migration up to expected was applied cleanly. A newer schema passes, migrations
+// are deployed before the code using them
+func CheckSchema(ctx context.Context, db *sqlx.DB, expected uint64) error {
+	version, dirty, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d failed halfway", version)
+	}
+	if version < expected {
+		return fmt.Errorf("schema version %d is behind %d", version, expected)
+	}
+
+	return nil
+}
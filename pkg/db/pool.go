@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/log/zapadapter"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// PoolConfig tunes the connection pool
+type PoolConfig struct {
+	// MaxOpenConns limits the open connections, 0 means no limit
+	MaxOpenConns int
+	// MaxIdleConns is the number of connections kept between queries, 0 closes them once released
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections after this duration, it must be shorter than the idle client timeout of
+	// the RDS proxy so the proxy never closes a connection the pool still holds. 0 keeps them forever
+	ConnMaxLifetime time.Duration
+	// ConnectTimeout bounds the time spent opening a connection, 0 waits as long as the driver does
+	ConnectTimeout time.Duration
+	// StaleAfter is how long a connection can sit unused before it's pinged prior to being reused. A lambda
+	// instance can be frozen for minutes, during which the server or a proxy may drop its connections. 0 never pings
+	StaleAfter time.Duration
+}
+
+// LambdaPoolConfig suits a lambda instance, which serves a single request at a time and keeps its connections
+// between invocations
+var LambdaPoolConfig = PoolConfig{
+	MaxOpenConns:    2,
+	MaxIdleConns:    2,
+	ConnMaxLifetime: 5 * time.Minute,
+	ConnectTimeout:  5 * time.Second,
+	StaleAfter:      30 * time.Second,
+}
+
+// Config describes how to connect to postgres
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Pass     string
+	Database string
+	Pool     PoolConfig
+	// Tunnel forwards connections through an ssh server when set
+	Tunnel *Tunnel
+	// TokenProvider replaces Pass with IAM auth tokens when set
+	TokenProvider *TokenProvider
+	// Metrics measures statements and samples the pool when set
+	Metrics server.Metrics
+}
+
+// Open creates a connection pool. No connection is made until the first query
+func Open(config Config) (*sqlx.DB, error) {
+	u := PostgresURL(config.Host, config.Port, config.User, config.Pass, config.Database)
+
+	connConfig, err := pgx.ParseConfig(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", u, err)
+	}
+
+	// assumes the global logger is already configured correctly, statements are traced and measured as they are logged
+	var logger pgx.Logger = zapadapter.NewLogger(zap.L())
+	if config.Metrics != nil {
+		logger = NewMetricsLogger(config.Metrics, logger)
+	}
+	connConfig.Logger = NewTracingLogger(logger)
+
+	if config.Pool.ConnectTimeout > 0 {
+		connConfig.ConnectTimeout = config.Pool.ConnectTimeout
+	}
+
+	// dialer is used to transmit data via SSH
+	if config.Tunnel != nil {
+		connConfig.DialFunc = config.Tunnel.Dial
+	}
+
+	checker := newStaleChecker(config.Pool.StaleAfter, config.Pool.ConnectTimeout)
+
+	options := []stdlib.OptionOpenDB{
+		stdlib.OptionAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			checker.used(conn)
+			return nil
+		}),
+		stdlib.OptionResetSession(func(ctx context.Context, conn *pgx.Conn) error {
+			return checker.check(ctx, conn)
+		}),
+	}
+
+	// tokens expire, so they're set on every connection instead of once in the url
+	if config.TokenProvider != nil {
+		options = append(options, stdlib.OptionBeforeConnect(config.TokenProvider.BeforeConnect))
+	}
+
+	db := stdlib.OpenDB(*connConfig, options...)
+
+	db.SetMaxOpenConns(config.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(config.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(config.Pool.ConnMaxLifetime)
+
+	if config.Metrics != nil {
+		config.Metrics.Collect(PoolCollector(db))
+	}
+
+	return sqlx.NewDb(db, "pgx"), nil
+}
+
+// pooledConn is implemented by *pgx.Conn
+type pooledConn interface {
+	Ping(ctx context.Context) error
+	IsClosed() bool
+}
+
+// staleChecker pings connections that were unused for a while before they're reused
+type staleChecker struct {
+	after       time.Duration
+	pingTimeout time.Duration
+
+	mu       sync.Mutex
+	lastUsed map[pooledConn]time.Time
+}
+
+func newStaleChecker(after time.Duration, pingTimeout time.Duration) *staleChecker {
+	return &staleChecker{after: after, pingTimeout: pingTimeout, lastUsed: map[pooledConn]time.Time{}}
+}
+
+// used records a new connection and forgets the ones the pool closed since
+func (s *staleChecker) used(conn pooledConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.lastUsed {
+		if c.IsClosed() {
+			delete(s.lastUsed, c)
+		}
+	}
+
+	s.lastUsed[conn] = time.Now()
+}
+
+// check returns driver.ErrBadConn when a connection unused for too long doesn't answer to ping anymore, so the
+// pool discards it and opens a new one
+func (s *staleChecker) check(ctx context.Context, conn pooledConn) error {
+	if s.after <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	last, ok := s.lastUsed[conn]
+	s.lastUsed[conn] = time.Now()
+	s.mu.Unlock()
+
+	if ok && time.Since(last) < s.after {
+		return nil
+	}
+
+	if s.pingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.pingTimeout)
+		defer cancel()
+	}
+
+	if err := conn.Ping(ctx); err != nil {
+		s.forget(conn)
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
+func (s *staleChecker) forget(conn pooledConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lastUsed, conn)
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fakeConn struct {
+	pings  int
+	err    error
+	closed bool
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	c.pings++
+	return c.err
+}
+
+func (c *fakeConn) IsClosed() bool {
+	return c.closed
+}
+
+func TestStaleChecker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	checker := newStaleChecker(time.Hour, time.Second)
+
+	conn := &fakeConn{}
+	checker.used(conn)
+
+	// recently used connections are reused without a round trip
+	assert.NoError(t, checker.check(ctx, conn))
+	assert.Equal(t, 0, conn.pings)
+
+	// a connection unused for longer than StaleAfter is pinged
+	checker.lastUsed[conn] = time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, checker.check(ctx, conn))
+	assert.Equal(t, 1, conn.pings)
+
+	// and discarded if it doesn't answer anymore
+	conn.err = errors.New("connection reset by peer")
+	checker.lastUsed[conn] = time.Now().Add(-2 * time.Hour)
+	assert.ErrorIs(t, checker.check(ctx, conn), driver.ErrBadConn)
+	assert.NotContains(t, checker.lastUsed, conn)
+}
+
+func TestStaleChecker_used(t *testing.T) {
+	t.Parallel()
+
+	checker := newStaleChecker(time.Hour, time.Second)
+
+	closed := &fakeConn{}
+	checker.used(closed)
+	closed.closed = true
+
+	// connections closed by the pool are forgotten when a new one is opened
+	checker.used(&fakeConn{})
+	assert.NotContains(t, checker.lastUsed, closed)
+	assert.Len(t, checker.lastUsed, 1)
+}
+
+func TestStaleChecker_disabled(t *testing.T) {
+	t.Parallel()
+
+	checker := newStaleChecker(0, time.Second)
+	conn := &fakeConn{err: errors.New("down")}
+
+	assert.NoError(t, checker.check(context.Background(), conn))
+	assert.Equal(t, 0, conn.pings)
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Backoff is an exponential backoff policy. The delay before attempt n+1 is Initial * Multiplier^(n-1), capped at
+// Max, with a random share of up to Jitter removed so concurrent callers don't retry in lockstep
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter is between 0 and 1
+	Jitter float64
+	// MaxElapsed stops retrying once the next attempt would start later than this after the first one, 0 means no limit
+	MaxElapsed time.Duration
+	// MaxAttempts counts the first attempt, 0 means no limit
+	MaxAttempts int
+}
+
+// DefaultBackoff suits queries and transactions run during a request
+var DefaultBackoff = Backoff{
+	Initial:     50 * time.Millisecond,
+	Max:         time.Second,
+	Multiplier:  2,
+	Jitter:      0.5,
+	MaxElapsed:  5 * time.Second,
+	MaxAttempts: 5,
+}
+
+// Delay returns the wait after the given attempt, starting at 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		delay -= delay * math.Min(b.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// Classifier reports whether an operation that failed with err may succeed if attempted again
+type Classifier func(err error) bool
+
+// retryableCodes are the SQLSTATE codes of transient failures
+var retryableCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// Retryable is the classifier of postgres errors worth retrying: serialization failures, deadlocks and lost
+// connections. Constraint violations and syntax errors fail the same way every time
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retryableCodes[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08") // connection_exception
+	}
+
+	// errors raised before anything was sent to the server
+	return errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err)
+}
+
+// Retry calls fn until it succeeds, fails with an error retryable rejects, the policy gives up or ctx is done. It
+// returns the last error of fn
+func Retry(ctx context.Context, policy Backoff, retryable Classifier, fn func(ctx context.Context) error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryTransaction runs fn in a transaction and runs it again in a new one when it fails with a retryable error,
+// fn must not have side effects outside the transaction
+func RetryTransaction(ctx context.Context, db *sqlx.DB, policy Backoff, fn func(tx *sqlx.Tx) error) error {
+	return Retry(ctx, policy, Retryable, func(ctx context.Context) error {
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
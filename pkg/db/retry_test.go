@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	t.Parallel()
+
+	b := Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+
+	assert.Equal(t, 10*time.Millisecond, b.Delay(1))
+	assert.Equal(t, 20*time.Millisecond, b.Delay(2))
+	assert.Equal(t, 40*time.Millisecond, b.Delay(3))
+	assert.Equal(t, 50*time.Millisecond, b.Delay(4))
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := b.Delay(2)
+		assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+		assert.LessOrEqual(t, delay, 20*time.Millisecond)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	pgErr := func(code string) error {
+		return QueryExecuteError(&pgconn.PgError{Code: code}, "UPDATE users SET username = $1", nil)
+	}
+
+	assert.True(t, Retryable(pgErr("40001")))
+	assert.True(t, Retryable(pgErr("40P01")))
+	assert.True(t, Retryable(pgErr("08006")))
+	assert.True(t, Retryable(pgErr("57P01")))
+	assert.True(t, Retryable(driver.ErrBadConn))
+
+	assert.False(t, Retryable(nil))
+	assert.False(t, Retryable(pgErr("23505")))
+	assert.False(t, Retryable(pgErr("42601")))
+	assert.False(t, Retryable(sql.ErrNoRows))
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	transient := func(err error) bool { return errors.Is(err, errTransient) }
+	policy := Backoff{Initial: time.Millisecond, Multiplier: 2, MaxAttempts: 3}
+
+	// succeeds after transient failures
+	attempts := 0
+	err := Retry(context.Background(), policy, transient, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// gives up after MaxAttempts with the last error
+	attempts = 0
+	err = Retry(context.Background(), policy, transient, func(ctx context.Context) error {
+		attempts++
+		return errTransient
+	})
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 3, attempts)
+
+	// permanent errors aren't retried
+	attempts = 0
+	err = Retry(context.Background(), policy, transient, func(ctx context.Context) error {
+		attempts++
+		return errPermanent
+	})
+	assert.ErrorIs(t, err, errPermanent)
+	assert.Equal(t, 1, attempts)
+
+	// the next attempt would start after MaxElapsed
+	attempts = 0
+	err = Retry(context.Background(), Backoff{Initial: time.Hour, MaxElapsed: time.Minute}, transient, func(ctx context.Context) error {
+		attempts++
+		return errTransient
+	})
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetry_Cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	always := func(err error) bool { return true }
+
+	attempts := 0
+	start := time.Now()
+	err := Retry(ctx, Backoff{Initial: time.Hour}, always, func(ctx context.Context) error {
+		attempts++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return errors.New("transient")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Minute)
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoHostKey is returned when a tunnel has no way to verify the host key of the server
+	ErrNoHostKey = errors.New("ssh host key verification needs a known_hosts file or a fingerprint")
+	// ErrNoAuthMethod is returned when a tunnel has neither a private key nor an agent
+	ErrNoAuthMethod = errors.New("ssh authentication needs a private key or an agent")
+	// ErrTunnelClosed is returned when dialing through a closed tunnel
+	ErrTunnelClosed = errors.New("ssh tunnel closed")
+)
+
+const (
+	DefaultSSHKeepAliveInterval = 30 * time.Second
+	DefaultSSHDialTimeout       = 10 * time.Second
+)
+
+// SSHConfig describes how to reach a bastion
+type SSHConfig struct {
+	User string
+	Host string
+	Port string
+	// Key is a PEM encoded private key, decrypted with KeyPassphrase when it's protected
+	Key           []byte
+	KeyPassphrase []byte
+	// UseAgent adds the keys of the agent listening on SSH_AUTH_SOCK
+	UseAgent bool
+	// KnownHostsFile pins the host key to the entries of a known_hosts file
+	KnownHostsFile string
+	// HostKeyFingerprint pins the host key to a SHA256 fingerprint, as printed by ssh-keygen -l
+	HostKeyFingerprint string
+	// KeepAliveInterval is how often the server is probed, a server that doesn't answer in time is redialed
+	KeepAliveInterval time.Duration
+	DialTimeout       time.Duration
+}
+
+// HostKeyCallback verifies the server against every configured pin
+func (config SSHConfig) HostKeyCallback() (ssh.HostKeyCallback, error) {
+	var callbacks []ssh.HostKeyCallback
+
+	if config.KnownHostsFile != "" {
+		callback, err := knownhosts.New(config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read known hosts: %w", err)
+		}
+		callbacks = append(callbacks, callback)
+	}
+
+	if fingerprint := config.HostKeyFingerprint; fingerprint != "" {
+		callbacks = append(callbacks, func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != fingerprint {
+				return fmt.Errorf("ssh host key mismatch for %s: got %s", hostname, ssh.FingerprintSHA256(key))
+			}
+			return nil
+		})
+	}
+
+	if len(callbacks) == 0 {
+		return nil, ErrNoHostKey
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, callback := range callbacks {
+			if err := callback(hostname, remote, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// authMethods returns the configured auth methods and the agent connection to close once done with them
+func (config SSHConfig) authMethods() ([]ssh.AuthMethod, net.Conn, error) {
+	var methods []ssh.AuthMethod
+
+	if len(config.Key) > 0 {
+		signer, err := parsePrivateKey(config.Key, config.KeyPassphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	var agentConn net.Conn
+	if config.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, nil, errors.New("ssh agent requested but SSH_AUTH_SOCK is not set")
+		}
+
+		var err error
+		if agentConn, err = net.Dial("unix", socket); err != nil {
+			return nil, nil, fmt.Errorf("unable to connect to ssh agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	if len(methods) == 0 {
+		return nil, nil, ErrNoAuthMethod
+	}
+
+	return methods, agentConn, nil
+}
+
+func parsePrivateKey(key []byte, passphrase []byte) (ssh.Signer, error) {
+	if len(passphrase) > 0 {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %w", err)
+		}
+		return signer, nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("private key is protected by a passphrase")
+		}
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	return signer, nil
+}
+
+// Tunnel forwards database connections through an ssh server. The ssh connection is probed with keepalives and
+// redialed when it's lost, so a long running process survives the bastion dropping it
+type Tunnel struct {
+	address       string
+	clientConfig  *ssh.ClientConfig
+	keepAlive     time.Duration
+	agentConn     net.Conn
+	closed        chan struct{}
+	closeOnce     sync.Once
+	keepAliveDone sync.WaitGroup
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// NewTunnel connects to the ssh server, the host key must match a pin
+func NewTunnel(config SSHConfig) (*Tunnel, error) {
+	hostKeyCallback, err := config.HostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	methods, agentConn, err := config.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	port := config.Port
+	if port == "" {
+		port = "22"
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = DefaultSSHDialTimeout
+	}
+	if config.KeepAliveInterval <= 0 {
+		config.KeepAliveInterval = DefaultSSHKeepAliveInterval
+	}
+
+	t := &Tunnel{
+		address: net.JoinHostPort(config.Host, port),
+		clientConfig: &ssh.ClientConfig{
+			User:            config.User,
+			Auth:            methods,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         config.DialTimeout,
+		},
+		keepAlive: config.KeepAliveInterval,
+		agentConn: agentConn,
+		closed:    make(chan struct{}),
+	}
+
+	if _, err = t.connect(); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	t.keepAliveDone.Add(1)
+	go t.keepAliveLoop()
+
+	return t, nil
+}
+
+// connect returns the current ssh client, dialing a new one if it was lost
+func (t *Tunnel) connect() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.closed:
+		return nil, ErrTunnelClosed
+	default:
+	}
+
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	client, err := ssh.Dial("tcp", t.address, t.clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ssh connection: %w", err)
+	}
+
+	t.client = client
+
+	// forget the client as soon as the server drops it, the next dial opens a new one
+	go func() {
+		_ = client.Wait()
+		t.reset(client)
+	}()
+
+	return client, nil
+}
+
+// reset forgets client if it's still the current one
+func (t *Tunnel) reset(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == client {
+		t.client = nil
+	}
+	_ = client.Close()
+}
+
+// Dial opens a connection to addr from the ssh server. It matches pgconn.DialFunc
+func (t *Tunnel) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialContext(ctx, client, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// the connection may have died since the last keepalive, a failed probe means it has
+	if ctx.Err() != nil || t.alive(client) {
+		return nil, err
+	}
+
+	t.reset(client)
+
+	if client, err = t.connect(); err != nil {
+		return nil, err
+	}
+
+	return dialContext(ctx, client, network, addr)
+}
+
+func dialContext(ctx context.Context, client *ssh.Client, network, addr string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(network, addr)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		// close the connection if it's opened after all
+		go func() {
+			if r := <-done; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// alive sends a keepalive request, a server not answering within the keepalive interval is considered gone
+func (t *Tunnel) alive(client *ssh.Client) bool {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err == nil
+	case <-time.After(t.keepAlive):
+		return false
+	}
+}
+
+func (t *Tunnel) keepAliveLoop() {
+	defer t.keepAliveDone.Done()
+
+	ticker := time.NewTicker(t.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.closed:
+			return
+		case <-ticker.C:
+		}
+
+		t.mu.Lock()
+		client := t.client
+		t.mu.Unlock()
+
+		if client == nil {
+			continue
+		}
+
+		if !t.alive(client) {
+			t.reset(client)
+			// redial right away so the next query doesn't pay for it, a failure is retried on the next tick
+			_, _ = t.connect()
+		}
+	}
+}
+
+// Close closes the ssh connection and every connection opened through it
+func (t *Tunnel) Close() error {
+	var err error
+
+	t.closeOnce.Do(func() {
+		close(t.closed)
+
+		t.mu.Lock()
+		if t.client != nil {
+			err = t.client.Close()
+			t.client = nil
+		}
+		t.mu.Unlock()
+
+		if t.agentConn != nil {
+			_ = t.agentConn.Close()
+		}
+	})
+
+	t.keepAliveDone.Wait()
+
+	return err
+}
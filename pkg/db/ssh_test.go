@@ -0,0 +1,270 @@
+package db
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	pemenc "encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSSHServer accepts one client key and forwards direct-tcpip channels
+type testSSHServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	s := &testSSHServer{listener: listener, hostKey: hostKey}
+	go s.serve(config)
+
+	return s
+}
+
+func (s *testSSHServer) serve(config *ssh.ServerConfig) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go func() {
+			_, channels, requests, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(requests)
+
+			for newChannel := range channels {
+				go forward(newChannel)
+			}
+		}()
+	}
+}
+
+// dropAll closes every client connection, as a bastion restarting would
+func (s *testSSHServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) hostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+func forward(newChannel ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+		newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		return
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	go func() {
+		io.Copy(channel, conn)
+		channel.Close()
+	}()
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+// newEchoServer returns the address of a server writing back what it reads
+func newEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func generateClientKey(t *testing.T) (ssh.Signer, []byte) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	return signer, pemenc.EncodeToMemory(&pemenc.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func assertEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestTunnel_Dial(t *testing.T) {
+	t.Parallel()
+
+	signer, key := generateClientKey(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+	host, port := server.hostPort()
+	echo := newEchoServer(t)
+
+	tunnel, err := NewTunnel(SSHConfig{
+		User:               "bastion",
+		Host:               host,
+		Port:               port,
+		Key:                key,
+		HostKeyFingerprint: ssh.FingerprintSHA256(server.hostKey.PublicKey()),
+		KeepAliveInterval:  time.Second,
+	})
+	require.NoError(t, err)
+	defer tunnel.Close()
+
+	conn, err := tunnel.Dial(context.Background(), "tcp", echo)
+	require.NoError(t, err)
+	assertEcho(t, conn)
+
+	// the tunnel redials once the bastion drops the connection
+	server.dropAll()
+
+	require.Eventually(t, func() bool {
+		conn, err := tunnel.Dial(context.Background(), "tcp", echo)
+		if err != nil {
+			return false
+		}
+		assertEcho(t, conn)
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, tunnel.Close())
+	_, err = tunnel.Dial(context.Background(), "tcp", echo)
+	assert.ErrorIs(t, err, ErrTunnelClosed)
+}
+
+func TestTunnel_HostKey(t *testing.T) {
+	t.Parallel()
+
+	signer, key := generateClientKey(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+	host, port := server.hostPort()
+
+	config := SSHConfig{User: "bastion", Host: host, Port: port, Key: key}
+
+	// refusing to connect without a pin
+	_, err := NewTunnel(config)
+	assert.ErrorIs(t, err, ErrNoHostKey)
+
+	otherKey, _ := generateClientKey(t)
+	config.HostKeyFingerprint = ssh.FingerprintSHA256(otherKey.PublicKey())
+	_, err = NewTunnel(config)
+	assert.Error(t, err)
+
+	// known_hosts entries pin the key as well
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, port))}, server.hostKey.PublicKey())
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600))
+
+	config.HostKeyFingerprint = ""
+	config.KnownHostsFile = knownHostsFile
+	tunnel, err := NewTunnel(config)
+	require.NoError(t, err)
+	tunnel.Close()
+}
+
+func TestParsePrivateKey_Passphrase(t *testing.T) {
+	t.Parallel()
+
+	private, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	// legacy encrypted PEM, as written by ssh-keygen -m PEM
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private), []byte("secret"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+	key := pemenc.EncodeToMemory(block)
+
+	_, err = parsePrivateKey(key, nil)
+	assert.EqualError(t, err, "private key is protected by a passphrase")
+
+	_, err = parsePrivateKey(key, []byte("wrong"))
+	assert.Error(t, err)
+
+	signer, err := parsePrivateKey(key, []byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, ssh.KeyAlgoRSA, signer.PublicKey().Type())
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// queryMessages are the messages pgx logs once a statement completes
+var queryMessages = map[string]bool{"Query": true, "Exec": true, "CopyFrom": true}
+
+// tracingLogger records a span for every statement pgx logs, then passes the entry on. pgx has no tracing hook,
+// but it logs each statement with its duration once it's done, in the context of the query
+type tracingLogger struct {
+	next pgx.Logger
+}
+
+// NewTracingLogger returns a pgx logger tracing statements before logging them with next
+func NewTracingLogger(next pgx.Logger) pgx.Logger {
+	return &tracingLogger{next}
+}
+
+func (l *tracingLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if queryMessages[msg] {
+		traceStatement(ctx, msg, data)
+	}
+
+	if l.next != nil {
+		l.next.Log(ctx, level, msg, data)
+	}
+}
+
+// traceStatement records a span ending now and lasting as long as pgx measured. Arguments are left out, they may
+// hold credentials or personal data
+func traceStatement(ctx context.Context, msg string, data map[string]interface{}) {
+	end := time.Now()
+	start := end
+	if elapsed, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-elapsed)
+	}
+
+	attributes := []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+	if sql, ok := data["sql"].(string); ok {
+		attributes = append(attributes, semconv.DBStatementKey.String(sql))
+	}
+	if rows, ok := data["rowCount"].(int); ok {
+		attributes = append(attributes, attribute.Int("db.rows", rows))
+	}
+
+	_, span := tracing.Start(ctx, "db."+msg,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+
+	err, _ := data["err"].(error)
+	tracing.End(span, err, trace.WithTimestamp(end))
+}
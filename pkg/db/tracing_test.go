@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	l.messages = append(l.messages, msg)
+}
+
+func TestTracingLogger(t *testing.T) {
+	provider, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterMemory, SampleRate: 1})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	next := &recordingLogger{}
+	logger := NewTracingLogger(next)
+
+	ctx, parent := tracing.Start(context.Background(), "UserService.Get")
+
+	logger.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql":      "SELECT * FROM users WHERE user_id = $1",
+		"args":     []interface{}{"usr_1"},
+		"time":     40 * time.Millisecond,
+		"rowCount": 1,
+	})
+	logger.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql": "DELETE FROM users",
+		"err": errors.New("permission denied"),
+	})
+	logger.Log(ctx, pgx.LogLevelInfo, "closed connection", nil)
+	parent.End()
+
+	// every entry is still logged
+	assert.Equal(t, []string{"Query", "Exec", "closed connection"}, next.messages)
+
+	spans := provider.Spans.GetSpans()
+	require.Len(t, spans, 3)
+
+	query := spans[0]
+	assert.Equal(t, "db.Query", query.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+	assert.Equal(t, 40*time.Millisecond, query.EndTime.Sub(query.StartTime))
+	assert.Contains(t, query.Attributes, semconv.DBStatementKey.String("SELECT * FROM users WHERE user_id = $1"))
+	for _, attribute := range query.Attributes {
+		// arguments may be personal data
+		assert.NotContains(t, attribute.Value.Emit(), "usr_1")
+	}
+
+	exec := spans[1]
+	assert.Equal(t, "db.Exec", exec.Name)
+	assert.Equal(t, codes.Error, exec.Status.Code)
+	assert.Equal(t, "permission denied", exec.Status.Description)
+}
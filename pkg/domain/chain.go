@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"fmt"
+)
+
+// ChainID is an EIP-155 chain ID
+type ChainID int64
+
+const ChainIDMainnet ChainID = 1
+
+// Wallet is an address on a specific chain
+type Wallet struct {
+	ChainID ChainID
+	Address EthereumAddress
+}
+
+func NewWallet(chainID ChainID, address EthereumAddress) Wallet {
+	return Wallet{
+		ChainID: chainID,
+		Address: address,
+	}
+}
+
+// String returns the CAIP-10 account ID of the wallet
+func (wallet Wallet) String() string {
+	return fmt.Sprintf("eip155:%d:%s", wallet.ChainID, wallet.Address.Hex())
+}
+
+func ValidateChainID(chainID ChainID) error {
+	if chainID <= 0 {
+		return ErrUnsupportedChain(nil)
+	}
+	return nil
+}
+
+type WalletInput struct {
+	EthereumAddressHexInput
+	ChainID ChainID
+}
+
+func NewWalletInput(addressHex string, chainID ChainID) WalletInput {
+	return WalletInput{
+		EthereumAddressHexInput: NewEthereumAddressHexInput(addressHex),
+		ChainID:                 chainID,
+	}
+}
+
+func (input WalletInput) Validate() error {
+	if err := input.EthereumAddressHexInput.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateChainID(input.ChainID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (input WalletInput) Wallet() Wallet {
+	return NewWallet(input.ChainID, input.Address())
+}
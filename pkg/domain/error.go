@@ -3,42 +3,127 @@ package domain
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"sort"
 )
 
 var (
-	ErrCodeUnexpected = NewError(1000, "Internal server error")
+	ErrCodeUnexpected = NewError(1000, http.StatusInternalServerError, SeverityError, "Internal server error")
 
-	ErrNotFound = NewError(3003, "Not found")
+	ErrNotFound            = NewError(1001, http.StatusNotFound, SeverityInfo, "Not found")
+	ErrConflict            = NewError(1002, http.StatusConflict, SeverityInfo, "Conflicts with an existing resource")
+	ErrConstraintViolation = NewError(1003, http.StatusUnprocessableEntity, SeverityWarn, "Violates a data constraint")
+	ErrDatabaseTimeout     = NewError(1004, http.StatusServiceUnavailable, SeverityError, "Database timed out")
+	ErrPreconditionFailed  = NewError(1005, http.StatusPreconditionFailed, SeverityInfo, "Resource was modified since it was fetched")
 
-	ErrInvalidEthereumAddressHex = NewError(2001, "ethereum address is not hex")
-	ErrInvalidSignatureSize      = NewError(2002, fmt.Sprintf("signature must be %d bytes", SignatureSize))
-	ErrInvalidSignatureHex       = NewError(2003, "signature is not hex")
-	ErrInvalidSignature          = NewError(2004, "signature is invalid")
+	ErrInvalidEthereumAddressHex      = NewError(2001, http.StatusUnprocessableEntity, SeverityInfo, "ethereum address is not hex")
+	ErrInvalidSignatureSize           = NewError(2002, http.StatusUnprocessableEntity, SeverityInfo, fmt.Sprintf("signature must be %d bytes", SignatureSize))
+	ErrInvalidSignatureHex            = NewError(2003, http.StatusUnprocessableEntity, SeverityInfo, "signature is not hex")
+	ErrInvalidSignature               = NewError(2004, http.StatusUnprocessableEntity, SeverityInfo, "signature is invalid")
+	ErrUnsupportedChain               = NewError(2005, http.StatusUnprocessableEntity, SeverityInfo, "chain is not supported")
+	ErrChainMismatch                  = NewError(2006, http.StatusUnprocessableEntity, SeverityInfo, "challenge was issued for another chain")
+	ErrInvalidEthereumAddressChecksum = NewError(2007, http.StatusUnprocessableEntity, SeverityInfo, "ethereum address checksum is invalid")
 
-	ErrUserGetFailed                    = NewError(3000, "failed to get user")
-	ErrUserFindByEthereumAddressFailed  = NewError(3000, "failed to find user by ethereum address user")
-	ErrUserStoreFailed                  = NewError(3001, "failed to store user")
-	ErrUserUpdateFailed                 = NewError(3002, "failed to update user")
-	ErrUserRemoveFailed                 = NewError(3003, "failed to remove user")
-	ErrUserUpdateDefaultCharacterFailed = NewError(3003, "failed to update user default character")
+	ErrUserGetFailed                    = NewError(3000, http.StatusInternalServerError, SeverityError, "failed to get user")
+	ErrUserStoreFailed                  = NewError(3001, http.StatusInternalServerError, SeverityError, "failed to store user")
+	ErrUserUpdateFailed                 = NewError(3002, http.StatusInternalServerError, SeverityError, "failed to update user")
+	ErrUserRemoveFailed                 = NewError(3003, http.StatusInternalServerError, SeverityError, "failed to remove user")
+	ErrUserFindByEthereumAddressFailed  = NewError(3004, http.StatusInternalServerError, SeverityError, "failed to find user by ethereum address")
+	ErrUserUpdateDefaultCharacterFailed = NewError(3005, http.StatusInternalServerError, SeverityError, "failed to update user default character")
 
-	ErrChallengeGetFailed    = NewError(4000, "failed to get challenge")
-	ErrChallengeStoreFailed  = NewError(4001, "failed to store challenge")
-	ErrChallengeRemoveFailed = NewError(4002, "failed to remove challenge")
+	ErrChallengeGetFailed    = NewError(4000, http.StatusInternalServerError, SeverityError, "failed to get challenge")
+	ErrChallengeStoreFailed  = NewError(4001, http.StatusInternalServerError, SeverityError, "failed to store challenge")
+	ErrChallengeRemoveFailed = NewError(4002, http.StatusInternalServerError, SeverityError, "failed to remove challenge")
 
-	ErrCharactersQueryFailed = NewError(5000, "failed to query characters")
-	ErrCharacterClaimFailed  = NewError(5001, "failed to claim character")
+	ErrCharactersQueryFailed = NewError(5000, http.StatusInternalServerError, SeverityError, "failed to query characters")
+	ErrCharacterClaimFailed  = NewError(5001, http.StatusInternalServerError, SeverityError, "failed to claim character")
 
-	ErrClansQueryFailed = NewError(6000, "failed to query clans")
+	ErrClansQueryFailed = NewError(6000, http.StatusInternalServerError, SeverityError, "failed to query clans")
+
+	ErrTransferLogsQueryFailed     = NewError(7000, http.StatusBadGateway, SeverityError, "failed to query transfer logs")
+	ErrOwnershipApplyFailed        = NewError(7001, http.StatusInternalServerError, SeverityError, "failed to apply transfers to ownerships")
+	ErrOwnershipRollbackFailed     = NewError(7002, http.StatusInternalServerError, SeverityError, "failed to rollback ownerships")
+	ErrOwnershipGetFailed          = NewError(7003, http.StatusInternalServerError, SeverityError, "failed to get ownership")
+	ErrIndexerCursorGetFailed      = NewError(7004, http.StatusInternalServerError, SeverityError, "failed to get indexer cursor")
+	ErrDefaultCharacterClearFailed = NewError(7005, http.StatusInternalServerError, SeverityError, "failed to clear default character")
+	ErrTokenHoldingCheckFailed     = NewError(7006, http.StatusInternalServerError, SeverityError, "failed to check token holding")
+	ErrInsufficientTokenHolding    = NewError(7007, http.StatusForbidden, SeverityInfo, "insufficient token holding")
+
+	ErrEnsResolveFailed = NewError(8000, http.StatusBadGateway, SeverityError, "failed to resolve ens name")
+	ErrEnsNameNotFound  = NewError(8001, http.StatusNotFound, SeverityInfo, "ens name not found")
+	ErrEnsRefreshFailed = NewError(8002, http.StatusInternalServerError, SeverityError, "failed to refresh ens profile")
+)
+
+// Severity is the level an error is logged at
+type Severity string
+
+const (
+	// SeverityInfo is for errors caused by the client
+	SeverityInfo Severity = "info"
+	// SeverityWarn is for client errors worth a look, such as data that the validation should have rejected
+	SeverityWarn Severity = "warn"
+	// SeverityError is for failures of the service or its dependencies
+	SeverityError Severity = "error"
 )
 
+// Definition declares a kind of error. Message is safe to return to clients
+type Definition struct {
+	Code     int      `json:"code"`
+	Status   int      `json:"status"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+var definitions = map[int]Definition{}
+
+// Register adds a kind of error to the catalog. Codes identify errors for clients, registering one twice panics
+func Register(def Definition) Definition {
+	if registered, ok := definitions[def.Code]; ok {
+		panic(fmt.Errorf("error code %d of %q is already registered by %q", def.Code, def.Message, registered.Message))
+	}
+
+	if def.Status < 400 || def.Status > 599 {
+		panic(fmt.Errorf("error code %d has invalid status %d", def.Code, def.Status))
+	}
+
+	definitions[def.Code] = def
+
+	return def
+}
+
+// Lookup returns the definition of an error code
+func Lookup(code int) (Definition, bool) {
+	def, ok := definitions[code]
+	return def, ok
+}
+
+// Definitions returns every registered error sorted by code
+func Definitions() []Definition {
+	defs := make([]Definition, 0, len(definitions))
+	for _, def := range definitions {
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+
+	return defs
+}
+
 type Error struct {
 	Code    int
 	Message string
-	Cause   error
+	// Params fill the placeholders of the localized message, such as {size}
+	Params map[string]interface{}
+	Cause  error
 }
 
-func NewError(code int, msg string) func(error) *Error {
+// NewError registers an error and returns its constructor, so errors are declared once without knowing the cause
+func NewError(code int, status int, severity Severity, msg string) func(error) *Error {
+	Register(Definition{Code: code, Status: status, Severity: severity, Message: msg})
+
 	return func(cause error) *Error {
 		e := errors.WithStack(cause)
 
@@ -50,6 +135,46 @@ func NewError(code int, msg string) func(error) *Error {
 	}
 }
 
+// Error returns the code and message followed by the cause. It's meant for logs, clients only get Message
 func (err *Error) Error() string {
-	return fmt.Sprintf("[%d] %s  %+v", err.Code, err.Message, err.Cause)
+	if err.Cause == nil {
+		return fmt.Sprintf("[%d] %s", err.Code, err.Message)
+	}
+
+	return fmt.Sprintf("[%d] %s: %v", err.Code, err.Message, err.Cause)
+}
+
+// Format prints the stack trace of the cause with %+v, like the errors of github.com/pkg/errors
+func (err *Error) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+') && err.Cause != nil:
+		fmt.Fprintf(s, "[%d] %s: %+v", err.Code, err.Message, err.Cause)
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	default:
+		io.WriteString(s, err.Error())
+	}
+}
+
+// WithParam sets a parameter of the localized message
+func (err *Error) WithParam(name string, value interface{}) *Error {
+	if err.Params == nil {
+		err.Params = map[string]interface{}{}
+	}
+
+	err.Params[name] = value
+
+	return err
+}
+
+// Unwrap returns the cause, so errors.Is and errors.As look past domain errors
+func (err *Error) Unwrap() error {
+	return err.Cause
+}
+
+// Is matches errors of the same code, so errors.Is(err, ErrNotFound(nil)) holds for any not found error
+func (err *Error) Is(target error) bool {
+	other, ok := target.(*Error)
+
+	return ok && other.Code == err.Code
 }
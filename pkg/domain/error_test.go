@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	// codes are unique
+	assert.Panics(t, func() {
+		NewError(ErrNotFound(nil).Code, http.StatusNotFound, SeverityInfo, "duplicate")
+	})
+
+	// definitions must have an error status
+	assert.Panics(t, func() {
+		Register(Definition{Code: 999999, Status: http.StatusOK, Severity: SeverityInfo, Message: "not an error"})
+	})
+
+	def, ok := Lookup(ErrNotFound(nil).Code)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, def.Status)
+	assert.Equal(t, "Not found", def.Message)
+
+	_, ok = Lookup(999999)
+	assert.False(t, ok)
+}
+
+func TestDefinitions(t *testing.T) {
+	t.Parallel()
+
+	defs := Definitions()
+	assert.NotEmpty(t, defs)
+
+	for i, def := range defs {
+		if i > 0 {
+			assert.Less(t, defs[i-1].Code, def.Code)
+		}
+
+		assert.NotEmpty(t, def.Message, def.Code)
+		assert.Contains(t, []Severity{SeverityInfo, SeverityWarn, SeverityError}, def.Severity, def.Code)
+	}
+}
+
+func TestError_Wrapping(t *testing.T) {
+	t.Parallel()
+
+	errCause := errors.New("no rows in result set")
+	err := fmt.Errorf("loading profile: %w", ErrNotFound(errCause))
+
+	assert.ErrorIs(t, err, ErrNotFound(nil))
+	assert.ErrorIs(t, err, errCause)
+	assert.NotErrorIs(t, err, ErrConflict(nil))
+
+	var domainErr *Error
+	assert.ErrorAs(t, err, &domainErr)
+	assert.Equal(t, ErrNotFound(nil).Code, domainErr.Code)
+}
+
+func TestError_Format(t *testing.T) {
+	t.Parallel()
+
+	err := ErrUserGetFailed(errors.New("connection refused"))
+
+	assert.Equal(t, "[3000] failed to get user: connection refused", err.Error())
+	assert.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	// the stack trace is only printed on demand
+	assert.Contains(t, fmt.Sprintf("%+v", err), "TestError_Format")
+	assert.NotContains(t, err.Error(), "TestError_Format")
+
+	assert.Equal(t, "[1001] Not found", ErrNotFound(nil).Error())
+}
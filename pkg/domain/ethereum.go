@@ -1,24 +1,92 @@
 package domain
 
-import "github.com/ethereum/go-ethereum/common"
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
 
+// EthereumAddress is the canonical form of an address. It is displayed and marshalled to JSON with its EIP-55
+// checksum and stored in lowercase, so lookups don't depend on the casing a client sent
 type EthereumAddress common.Address
 
-func NewEthereumAddressFromHex(addressHex string) EthereumAddress {
-	return EthereumAddress(common.HexToAddress(addressHex))
+// ParseEthereumAddress parses a 0x prefixed hex address. Mixed case addresses must have a valid EIP-55 checksum,
+// all lowercase and all uppercase addresses are accepted as is
+func ParseEthereumAddress(addressHex string) (EthereumAddress, error) {
+	if !strings.HasPrefix(addressHex, "0x") || !common.IsHexAddress(addressHex) {
+		return EthereumAddress{}, ErrInvalidEthereumAddressHex(nil)
+	}
+
+	address := EthereumAddress(common.HexToAddress(addressHex))
+
+	digits := addressHex[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && address.Hex() != addressHex {
+		return EthereumAddress{}, ErrInvalidEthereumAddressChecksum(nil)
+	}
+
+	return address, nil
 }
 
+// Hex returns the EIP-55 checksummed address
 func (address EthereumAddress) Hex() string {
 	return common.Address(address).Hex()
 }
 
-func ValidateEthereumAddressHex(address string) error {
-	if !common.IsHexAddress(address) {
-		return ErrInvalidEthereumAddressHex(nil)
+func (address EthereumAddress) String() string {
+	return address.Hex()
+}
+
+func (address EthereumAddress) IsZero() bool {
+	return address == EthereumAddress{}
+}
+
+func (address EthereumAddress) MarshalText() ([]byte, error) {
+	return []byte(address.Hex()), nil
+}
+
+func (address *EthereumAddress) UnmarshalText(text []byte) error {
+	parsed, err := ParseEthereumAddress(string(text))
+	if err != nil {
+		return err
 	}
+
+	*address = parsed
+
 	return nil
 }
 
+// Scan reads an address stored in any casing
+func (address *EthereumAddress) Scan(src interface{}) error {
+	var addressHex string
+
+	switch v := src.(type) {
+	case string:
+		addressHex = v
+	case []byte:
+		addressHex = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an ethereum address", src)
+	}
+
+	if !common.IsHexAddress(addressHex) {
+		return fmt.Errorf("cannot scan %q into an ethereum address", addressHex)
+	}
+
+	*address = EthereumAddress(common.HexToAddress(addressHex))
+
+	return nil
+}
+
+func (address EthereumAddress) Value() (driver.Value, error) {
+	return strings.ToLower(address.Hex()), nil
+}
+
+func ValidateEthereumAddressHex(addressHex string) error {
+	_, err := ParseEthereumAddress(addressHex)
+	return err
+}
+
 type EthereumAddressHexInput struct {
 	EthereumAddressHex string
 }
@@ -36,6 +104,8 @@ func (input EthereumAddressHexInput) Validate() error {
 	return nil
 }
 
+// Address returns the parsed address. The input must have been validated
 func (input EthereumAddressHexInput) Address() EthereumAddress {
-	return NewEthereumAddressFromHex(input.EthereumAddressHex)
+	address, _ := ParseEthereumAddress(input.EthereumAddressHex)
+	return address
 }
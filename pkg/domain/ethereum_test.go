@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// checksummed address from the EIP-55 test vectors
+const testAddressHex = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+func TestParseEthereumAddress(t *testing.T) {
+	t.Parallel()
+
+	for _, addressHex := range []string{
+		testAddressHex,
+		strings.ToLower(testAddressHex),
+		"0x" + strings.ToUpper(testAddressHex[2:]),
+	} {
+		address, err := ParseEthereumAddress(addressHex)
+		require.NoError(t, err, addressHex)
+		assert.Equal(t, testAddressHex, address.Hex())
+	}
+
+	_, err := ParseEthereumAddress("0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	require.Error(t, err)
+	assert.Equal(t, ErrInvalidEthereumAddressChecksum(nil).Code, err.(*Error).Code)
+
+	for _, addressHex := range []string{"", "0x", testAddressHex[2:], testAddressHex + "00", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeZ"} {
+		_, err = ParseEthereumAddress(addressHex)
+		require.Error(t, err, addressHex)
+		assert.Equal(t, ErrInvalidEthereumAddressHex(nil).Code, err.(*Error).Code)
+	}
+}
+
+func TestEthereumAddress_JSON(t *testing.T) {
+	t.Parallel()
+
+	var v struct {
+		Address EthereumAddress `json:"address"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"address":"`+strings.ToLower(testAddressHex)+`"}`), &v))
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"address":"`+testAddressHex+`"}`, string(b))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"address":"0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}`), &v))
+}
+
+func TestEthereumAddress_SQL(t *testing.T) {
+	t.Parallel()
+
+	address, err := ParseEthereumAddress(testAddressHex)
+	require.NoError(t, err)
+
+	value, err := address.Value()
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToLower(testAddressHex), value)
+
+	// rows stored before addresses were normalized may use any casing
+	var scanned EthereumAddress
+	require.NoError(t, scanned.Scan([]byte(testAddressHex)))
+	assert.Equal(t, address, scanned)
+
+	assert.Error(t, scanned.Scan("not an address"))
+	assert.Error(t, scanned.Scan(42))
+}
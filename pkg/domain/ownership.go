@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Transfer is an ERC-721 Transfer event as emitted on chain
+type Transfer struct {
+	ChainID         ChainID         `db:"chain_id" json:"chain_id"`
+	ContractAddress EthereumAddress `db:"contract_address" json:"contract_address"`
+	TokenID         string          `db:"token_id" json:"token_id"`
+	FromAddress     EthereumAddress `db:"from_address" json:"from_address"`
+	ToAddress       EthereumAddress `db:"to_address" json:"to_address"`
+	BlockNumber     uint64          `db:"block_number" json:"block_number"`
+	BlockHash       string          `db:"block_hash" json:"block_hash"`
+	LogIndex        uint            `db:"log_index" json:"log_index"`
+	TransactionHash string          `db:"transaction_hash" json:"transaction_hash"`
+	CreatedAt       time.Time       `db:"created_at" json:"created_at"`
+}
+
+// Ownership is the current owner of a token, as of the transfer identified by BlockNumber and LogIndex
+type Ownership struct {
+	ChainID         ChainID         `db:"chain_id" json:"chain_id"`
+	ContractAddress EthereumAddress `db:"contract_address" json:"contract_address"`
+	TokenID         string          `db:"token_id" json:"token_id"`
+	OwnerAddress    EthereumAddress `db:"owner_address" json:"owner_address"`
+	BlockNumber     uint64          `db:"block_number" json:"block_number"`
+	LogIndex        uint            `db:"log_index" json:"log_index"`
+	UpdatedAt       time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// NewOwnershipFromTransfer returns the ownership resulting from a transfer
+func NewOwnershipFromTransfer(transfer Transfer) Ownership {
+	return Ownership{
+		ChainID:         transfer.ChainID,
+		ContractAddress: transfer.ContractAddress,
+		TokenID:         transfer.TokenID,
+		OwnerAddress:    transfer.ToAddress,
+		BlockNumber:     transfer.BlockNumber,
+		LogIndex:        transfer.LogIndex,
+	}
+}
+
+// IndexerCursor is the last block ingested by an indexer. BlockHash is kept to detect reorgs
+type IndexerCursor struct {
+	Name        string    `db:"name"`
+	ChainID     ChainID   `db:"chain_id"`
+	BlockNumber uint64    `db:"block_number"`
+	BlockHash   string    `db:"block_hash"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
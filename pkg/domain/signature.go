@@ -32,7 +32,7 @@ func ValidateSignatureHex(sigHex string) error {
 	}
 
 	if len(sigHex) != 2*SignatureSize {
-		return ErrInvalidSignatureSize(nil)
+		return ErrInvalidSignatureSize(nil).WithParam("size", SignatureSize)
 	}
 	if !helpers.IsHex(sigHex) {
 		return ErrInvalidSignatureHex(nil)
@@ -2,22 +2,28 @@ package domain
 
 import (
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/manta-coder/golang-serverless-example/pkg/i18n"
 	"strings"
 	"time"
 )
 
 type User struct {
-	UserID             string    `db:"user_id" json:"user_id"`
-	EthereumAddressHex string    `db:"ethereum_address" json:"ethereum_address"`
-	Username           string    `db:"username" json:"username"`
-	DefaultCharacterID *string   `db:"default_character_id" json:"default_character_id"`
-	UpdatedAt          time.Time `db:"updated_at" json:"updated_at"`
-	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+	UserID             string          `db:"user_id" json:"user_id"`
+	EthereumAddress    EthereumAddress `db:"ethereum_address" json:"ethereum_address"`
+	Username           string          `db:"username" json:"username"`
+	DefaultCharacterID *string         `db:"default_character_id" json:"default_character_id"`
+	EnsName            *string         `db:"ens_name" json:"ens_name"`
+	EnsAvatar          *string         `db:"ens_avatar" json:"ens_avatar"`
+	EnsRefreshedAt     *time.Time      `db:"ens_refreshed_at" json:"-"`
+	Locale             string          `db:"locale" json:"locale"`
+	Version            int             `db:"version" json:"-"`
+	UpdatedAt          time.Time       `db:"updated_at" json:"updated_at"`
+	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
 }
 
 type UserStoreInput struct {
 	EthereumAddressHexInput
-	Username string
+	Username string `json:"username"`
 }
 
 func NewUserStoreInput(addressHex string, Username string) UserStoreInput {
@@ -31,35 +37,72 @@ func (input *UserStoreInput) sanitize() {
 	input.Username = strings.TrimSpace(input.Username)
 }
 
-func (input UserStoreInput) Validate() error {
+// Validate sanitizes the input in place, then checks it
+func (input *UserStoreInput) Validate() error {
 	if err := input.EthereumAddressHexInput.Validate(); err != nil {
 		return err
 	}
 	input.sanitize()
-	return validation.ValidateStruct(&input,
+	return validation.ValidateStruct(input,
 		validation.Field(&input.Username, validation.Length(3, 20)),
 	)
 }
 
+// UserUpdateInput holds the fields to change, the ones left nil keep their value
 type UserUpdateInput struct {
-	UserID   string
-	Username string
+	UserID   string  `json:"-"`
+	Username *string `json:"username"`
+	// Locale is the language of messages sent to the user, empty lets the client decide
+	Locale *string `json:"locale"`
+	// Version is the version the client last saw, 0 skips the check
+	Version int `json:"-"`
 }
 
-func NewUserUpdateInput(userID string, username string) UserUpdateInput {
+func NewUserUpdateInput(userID string, version int) UserUpdateInput {
 	return UserUpdateInput{
-		UserID:   userID,
-		Username: username,
+		UserID:  userID,
+		Version: version,
 	}
 }
 
+// WithUsername sets the username to change
+func (input UserUpdateInput) WithUsername(username string) UserUpdateInput {
+	input.Username = &username
+	return input
+}
+
+// WithLocale sets the locale to change
+func (input UserUpdateInput) WithLocale(locale string) UserUpdateInput {
+	input.Locale = &locale
+	return input
+}
+
 func (input *UserUpdateInput) sanitize() {
-	input.Username = strings.TrimSpace(input.Username)
+	if input.Username != nil {
+		username := strings.TrimSpace(*input.Username)
+		input.Username = &username
+	}
+	if input.Locale != nil {
+		locale := strings.TrimSpace(*input.Locale)
+		input.Locale = &locale
+	}
 }
 
-func (input UserUpdateInput) Validate() error {
+// Validate sanitizes the input in place, then checks it
+func (input *UserUpdateInput) Validate() error {
 	input.sanitize()
-	return validation.ValidateStruct(&input,
-		validation.Field(&input.Username, validation.Length(3, 20)),
+	return validation.ValidateStruct(input,
+		validation.Field(&input.Username, validation.NilOrNotEmpty, validation.Length(3, 20)),
+		validation.Field(&input.Locale, validation.In(locales()...)),
 	)
 }
+
+// locales returns the supported locales as validation.In takes them
+func locales() []interface{} {
+	result := make([]interface{}, len(i18n.Locales))
+	for i, locale := range i18n.Locales {
+		result[i] = locale
+	}
+
+	return result
+}
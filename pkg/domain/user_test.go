@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUserUpdateInput_Validate(t *testing.T) {
+	t.Parallel()
+
+	// the input is sanitized in place, so what's stored is what was validated
+	input := NewUserUpdateInput("usr_1", 0).WithLocale(" ja ")
+	require.NoError(t, input.Validate())
+	assert.Equal(t, "ja", *input.Locale)
+	assert.Nil(t, input.Username)
+
+	input = NewUserUpdateInput("usr_1", 0).WithUsername("  renamed ")
+	require.NoError(t, input.Validate())
+	assert.Equal(t, "renamed", *input.Username)
+	assert.Nil(t, input.Locale)
+
+	// an empty locale lets the client decide, an empty username isn't allowed
+	for _, tt := range []struct {
+		name  string
+		input UserUpdateInput
+		valid bool
+	}{
+		{"empty locale", NewUserUpdateInput("usr_1", 0).WithLocale(""), true},
+		{"no field", NewUserUpdateInput("usr_1", 0), true},
+		{"blank username", NewUserUpdateInput("usr_1", 0).WithUsername(" "), false},
+		{"unsupported locale", NewUserUpdateInput("usr_1", 0).WithLocale("de"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.input.Validate() == nil)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ConfigFileEnv holds the path of an optional YAML file of config values
+	ConfigFileEnv = "CONFIG_FILE"
+	// ConfigSecretsURLEnv holds an optional file://, secretsmanager:// or ssm:// url of config values
+	ConfigSecretsURLEnv = "CONFIG_SECRETS_URL"
+)
+
+// LoadConfig fills v, a struct with env tags, from layered sources, each one overriding the previous: envDefault
+// tags, the file at CONFIG_FILE, the secrets at CONFIG_SECRETS_URL and the environment. Every source is keyed by
+// env variable name
+func LoadConfig(v interface{}) error {
+	return loadConfig(v, environ(), helpers.LoadSecrets)
+}
+
+func loadConfig(v interface{}, environment map[string]string, loadSecrets func(rawurl string) (map[string]string, error)) error {
+	values := map[string]string{}
+
+	if filename := environment[ConfigFileEnv]; filename != "" {
+		file, err := helpers.LoadValuesFile(filename)
+		if err != nil {
+			return err
+		}
+		merge(values, file)
+	}
+
+	// the file may point to the secrets, the environment still has the last word
+	secretsURL := values[ConfigSecretsURLEnv]
+	if u := environment[ConfigSecretsURLEnv]; u != "" {
+		secretsURL = u
+	}
+
+	if secretsURL != "" {
+		secrets, err := loadSecrets(secretsURL)
+		if err != nil {
+			return err
+		}
+		merge(values, secrets)
+	}
+
+	merge(values, environment)
+
+	if err := helpers.DecodeValuesInto(v, values); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return nil
+}
+
+// merge copies the non empty values of src into dst. Templates declare every variable even when it's unset, an
+// empty variable must not hide a value from a previous source
+func merge(dst map[string]string, src map[string]string) {
+	for key, value := range src {
+		if value != "" {
+			dst[key] = value
+		}
+	}
+}
+
+func environ() map[string]string {
+	environment := map[string]string{}
+	for _, variable := range os.Environ() {
+		if key, value, ok := strings.Cut(variable, "="); ok {
+			environment[key] = value
+		}
+	}
+
+	return environment
+}
+
+var (
+	// stageName is what API Gateway accepts as stage name
+	stageName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// headerName is a token, as defined by RFC 7230
+	headerName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+)
+
+func validateOrigin(value interface{}) error {
+	origin, _ := value.(string)
+	return server.ValidateOrigin(origin)
+}
+
+// Requirement returns the problems of a config for a given use, keyed by env variable name
+type Requirement func(config Config) validation.Errors
+
+// Validate checks the settings every entry point needs, then requirements, and returns every problem at once
+func (config Config) Validate(requirements ...Requirement) error {
+	errs := validation.Errors{
+		"DB_HOST":                      validation.Validate(config.DBHost, validation.Required),
+		"DB_PORT":                      validation.Validate(config.DBPort, validation.Required),
+		"DB_NAME":                      validation.Validate(config.DBName, validation.Required),
+		"DB_USER":                      validation.Validate(config.DBUser, validation.Required),
+		"DB_AUTH":                      validation.Validate(config.DBAuth, validation.In(DBAuthPassword, DBAuthIAM)),
+		"DB_MAX_OPEN_CONNS":            validation.Validate(config.DBMaxOpenConns, validation.Min(0)),
+		"DB_MAX_IDLE_CONNS":            validation.Validate(config.DBMaxIdleConns, validation.Min(0)),
+		"DB_CONN_MAX_LIFETIME_SECONDS": validation.Validate(config.DBConnMaxLifetimeSeconds, validation.Min(0)),
+		"DB_CONNECT_TIMEOUT_SECONDS":   validation.Validate(config.DBConnectTimeoutSeconds, validation.Min(0)),
+		"DB_STALE_AFTER_SECONDS":       validation.Validate(config.DBStaleAfterSeconds, validation.Min(0)),
+		"LOGS_MAX_BODY_BYTES":          validation.Validate(config.LogsMaxBodyBytes, validation.Min(0)),
+		"LOGS_SUCCESS_SAMPLE_RATE":     validation.Validate(config.LogsSuccessSampleRate, validation.Min(0.0), validation.Max(1.0)),
+		"TRACING_EXPORTER":             validation.Validate(config.TracingExporter, validation.In(tracing.Exporters...)),
+		"TRACING_SAMPLE_RATE":          validation.Validate(config.TracingSampleRate, validation.Min(0.0), validation.Max(1.0)),
+		"METRICS_BACKEND":              validation.Validate(config.MetricsBackend, validation.In(server.MetricsBackends...)),
+		"API_STAGES":                   validation.Validate(config.APIStages, validation.Each(validation.Required, validation.Match(stageName))),
+		"CORS_ALLOW_ORIGINS":           validation.Validate(config.CORSAllowOrigins, validation.Each(validation.Required, validation.By(validateOrigin))),
+		"CORS_ALLOW_METHODS":           validation.Validate(config.CORSAllowMethods, validation.Each(validation.In(server.Methods...))),
+		"CORS_ALLOW_HEADERS":           validation.Validate(config.CORSAllowHeaders, validation.Each(validation.Required, validation.Match(headerName))),
+		"SUPPORTED_CHAIN_IDS":          validation.Validate(config.SupportedChainIDs, validation.Required, validation.Each(validation.Required, validation.Min(int64(1)))),
+		"HOLDING_SOURCE":               validation.Validate(config.HoldingSource, validation.In(HoldingSourceIndexed, HoldingSourceChain)),
+	}
+
+	if _, err := config.LogConfig(); err != nil {
+		errs["LOGS_REDACT_PATTERNS"] = err
+	}
+
+	// any site could make requests on behalf of the users
+	if config.CORSAllowCredentials {
+		for _, origin := range config.CORSAllowOrigins {
+			if origin == server.AnyOrigin {
+				errs["CORS_ALLOW_CREDENTIALS"] = errors.New("can't be set when CORS_ALLOW_ORIGINS allows any origin")
+			}
+		}
+	}
+
+	if config.DBAuth == DBAuthIAM {
+		errs["AWS_REGION"] = validation.Validate(config.AWSRegion, validation.Required)
+	} else {
+		errs["DB_PASS"] = validation.Validate(config.DBPass, validation.Required)
+	}
+
+	// a tunnel must pin the bastion host key, connecting to whatever answers would expose the database credentials
+	if config.SSHHost != "" {
+		errs["SSH_USER"] = validation.Validate(config.SSHUser, validation.Required)
+		errs["SSH_KEEPALIVE_SECONDS"] = validation.Validate(config.SSHKeepAliveSeconds, validation.Min(0))
+		if config.SSHKeyFile == "" && !config.SSHUseAgent {
+			errs["SSH_KEY_FILE"] = errors.New("required unless SSH_USE_AGENT is set")
+		}
+		if config.SSHKnownHostsFile == "" && config.SSHHostKeyFingerprint == "" {
+			errs["SSH_KNOWN_HOSTS_FILE"] = errors.New("required unless SSH_HOST_KEY_FINGERPRINT is set")
+		}
+	}
+
+	for _, requirement := range requirements {
+		for key, err := range requirement(config) {
+			errs[key] = err
+		}
+	}
+
+	return errs.Filter()
+}
+
+// Warnings returns the settings that are accepted but weak, keyed by env variable name
+func (config Config) Warnings() validation.Errors {
+	errs := validation.Errors{}
+
+	// HS256 keys shorter than the hash are easier to brute force. Existing deployments may use shorter secrets, so
+	// it's not an error
+	if err := validation.Validate(config.AuthSecret, validation.Length(32, 0)); err != nil {
+		errs["AUTH_SECRET"] = err
+	}
+
+	return errs
+}
+
+// Requires combines requirements into one
+func Requires(requirements ...Requirement) Requirement {
+	return func(config Config) validation.Errors {
+		errs := validation.Errors{}
+		for _, requirement := range requirements {
+			for key, err := range requirement(config) {
+				errs[key] = err
+			}
+		}
+
+		return errs
+	}
+}
+
+// RequireAuth checks the settings used to sign and verify tokens
+func RequireAuth(config Config) validation.Errors {
+	return validation.Errors{
+		"AUTH_SECRET":                        validation.Validate(config.AuthSecret, validation.Required),
+		"AUTH_TOKEN_EXPIRY_DURATION_SECONDS": validation.Validate(config.AuthTokenExpiryDurationSeconds, validation.Required, validation.Min(1)),
+	}
+}
+
+// RequireEns checks the node used to resolve ens names
+func RequireEns(config Config) validation.Errors {
+	return validation.Errors{
+		"ETHEREUM_RPC_URL": validation.Validate(config.EthereumRPCURL, validation.Required),
+	}
+}
+
+// RequireIndexer checks the settings of the ERC-721 indexer. It only needs the node of the chain it indexes, the user
+// service it uses to clear default characters connects to ETHEREUM_RPC_URL on the first ens resolution
+func RequireIndexer(config Config) validation.Errors {
+	errs := validation.Errors{
+		"INDEXER_CONTRACTS":  validation.Validate(config.IndexerContracts, validation.Required),
+		"INDEXER_CHAIN_ID":   validation.Validate(config.IndexerChainID, validation.Min(int64(1))),
+		"INDEXER_BATCH_SIZE": validation.Validate(config.IndexerBatchSize, validation.Min(uint64(1))),
+	}
+
+	if config.IndexerChainID > 0 && config.RPCURL(config.IndexerChainID) == "" {
+		errs["CHAIN_RPC_URLS"] = fmt.Errorf("no rpc url for chain %d", config.IndexerChainID)
+	}
+
+	return errs
+}
+
+// RequireEnsRefresh checks the settings of the ens refresh job
+func RequireEnsRefresh(config Config) validation.Errors {
+	return validation.Errors{
+		"ETHEREUM_RPC_URL":            validation.Validate(config.EthereumRPCURL, validation.Required),
+		"ENS_REFRESH_MAX_AGE_SECONDS": validation.Validate(config.EnsRefreshMaxAgeSeconds, validation.Min(1)),
+		"ENS_REFRESH_BATCH_SIZE":      validation.Validate(config.EnsRefreshBatchSize, validation.Min(uint64(1))),
+	}
+}
+
+// RequireTokenGate checks the settings of the routes gated behind a token holding, the gate is off when
+// TOKEN_GATE_CONTRACT is unset
+func RequireTokenGate(config Config) validation.Errors {
+	if config.TokenGateContract == (domain.EthereumAddress{}) {
+		return nil
+	}
+
+	errs := validation.Errors{
+		"TOKEN_GATE_MIN_BALANCE": validation.Validate(config.TokenGateMinBalance, validation.Min(uint64(1))),
+	}
+
+	if config.HoldingSource == HoldingSourceChain {
+		for _, chainID := range config.SupportedChainIDs {
+			if config.RPCURL(chainID) == "" {
+				errs["CHAIN_RPC_URLS"] = fmt.Errorf("no rpc url for chain %d", chainID)
+			}
+		}
+	}
+
+	return errs
+}
+
+// RequirePrometheus checks metrics can be scraped
+func RequirePrometheus(config Config) validation.Errors {
+	return validation.Errors{
+		"METRICS_BACKEND": validation.Validate(config.MetricsBackend, validation.Required, validation.In(server.MetricsBackendPrometheus)),
+	}
+}
+
+// MustLoadConfig loads and validates the config or panics with every problem found
+func MustLoadConfig(requirements ...Requirement) Config {
+	var config Config
+	if err := LoadConfig(&config); err != nil {
+		panic(fmt.Errorf("failed to load config: %w", err))
+	}
+
+	if err := config.Validate(requirements...); err != nil {
+		panic(fmt.Errorf("invalid config: %w", err))
+	}
+
+	return config
+}
@@ -0,0 +1,367 @@
+package engine
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+	return filename
+}
+
+func TestLoadConfig_Layers(t *testing.T) {
+	t.Parallel()
+
+	secretsFile := writeFile(t, "secrets.json", `{"DB_PASS": "from-secrets", "DB_USER": "from-secrets"}`)
+	configFile := writeFile(t, "config.yaml", `
+DB_HOST: from-file
+DB_USER: from-file
+DB_PASS: from-file
+DB_MAX_OPEN_CONNS: 10
+SUPPORTED_CHAIN_IDS: [1, 42161]
+CONFIG_SECRETS_URL: file://`+secretsFile+`
+`)
+
+	var config Config
+	err := loadConfig(&config, map[string]string{
+		ConfigFileEnv: configFile,
+		"DB_USER":     "from-env",
+		// templates declare unset variables as empty strings
+		"DB_HOST": "",
+	}, helpers.LoadSecrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, "from-file", config.DBHost)
+	assert.Equal(t, "from-secrets", config.DBPass)
+	assert.Equal(t, "from-env", config.DBUser)
+	require.NotNil(t, config.DBMaxOpenConns)
+	assert.Equal(t, 10, *config.DBMaxOpenConns)
+	assert.Equal(t, []domain.ChainID{1, 42161}, config.SupportedChainIDs)
+
+	// envDefault applies when no source sets a value
+	assert.Equal(t, DBAuthPassword, config.DBAuth)
+	assert.Equal(t, uint64(1000), config.IndexerBatchSize)
+}
+
+func TestLoadConfig_IndexerContracts(t *testing.T) {
+	t.Parallel()
+
+	var config Config
+	err := loadConfig(&config, map[string]string{
+		"INDEXER_CONTRACTS":          "0x5aeda56215b167893e80b4fe645ba6d5bab767de,0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"INDEXER_CHARACTER_CONTRACT": "0x5AEDA56215b167893e80B4fE645BA6d5Bab767DE",
+	}, helpers.LoadSecrets)
+	require.NoError(t, err)
+
+	require.Len(t, config.IndexerContracts, 2)
+	assert.Equal(t, config.IndexerCharacterContract, config.IndexerContracts[0])
+	assert.Equal(t, "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", config.IndexerContracts[1].Hex())
+
+	// a mistyped address fails to load instead of indexing another contract
+	err = loadConfig(&config, map[string]string{
+		"INDEXER_CONTRACTS": "0x5AEDA56215b167893e80B4fE645BA6d5Bab767De",
+	}, helpers.LoadSecrets)
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_SecretsURLFromEnv(t *testing.T) {
+	t.Parallel()
+
+	var loaded string
+	loadSecrets := func(rawurl string) (map[string]string, error) {
+		loaded = rawurl
+		return map[string]string{"AUTH_SECRET": "from-ssm"}, nil
+	}
+
+	var config Config
+	err := loadConfig(&config, map[string]string{ConfigSecretsURLEnv: "ssm://app/prod"}, loadSecrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ssm://app/prod", loaded)
+	assert.Equal(t, "from-ssm", config.AuthSecret)
+
+	errSecrets := errors.New("access denied")
+	err = loadConfig(&config, map[string]string{ConfigSecretsURLEnv: "ssm://app/prod"}, func(string) (map[string]string, error) {
+		return nil, errSecrets
+	})
+	assert.ErrorIs(t, err, errSecrets)
+}
+
+func validTestConfig() Config {
+	return Config{
+		DBHost:                         "localhost",
+		DBPort:                         "5432",
+		DBName:                         "app",
+		DBUser:                         "app",
+		DBPass:                         "secret",
+		DBAuth:                         DBAuthPassword,
+		EthereumRPCURL:                 "http://localhost:8545",
+		SupportedChainIDs:              []domain.ChainID{domain.ChainIDMainnet},
+		AuthSecret:                     "123456789abcdefghijklmnopqrstuvwyz",
+		AuthTokenExpiryDurationSeconds: 900,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	require.NoError(t, config.Validate(RequireAuth))
+
+	// every problem is reported at once
+	config.DBHost = ""
+	config.DBPass = ""
+	config.AuthSecret = ""
+	err := config.Validate(RequireAuth)
+	require.Error(t, err)
+
+	var errs validation.Errors
+	require.True(t, errors.As(err, &errs))
+	assert.Len(t, errs, 3)
+	assert.Contains(t, errs, "DB_HOST")
+	assert.Contains(t, errs, "DB_PASS")
+	assert.Contains(t, errs, "AUTH_SECRET")
+
+	// only the modules resolving ens names need a node
+	config = validTestConfig()
+	config.EthereumRPCURL = ""
+	assert.NoError(t, config.Validate(RequireAuth))
+	err = config.Validate(Requires(RequireAuth, RequireEns))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ETHEREUM_RPC_URL")
+
+	// iam auth needs a region instead of a password
+	config = validTestConfig()
+	config.DBPass = ""
+	config.DBAuth = DBAuthIAM
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AWS_REGION")
+
+	config.AWSRegion = "eu-west-1"
+	assert.NoError(t, config.Validate())
+
+	config.DBAuth = "kerberos"
+	assert.Error(t, config.Validate())
+}
+
+func TestConfig_Warnings(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	assert.Empty(t, config.Warnings())
+
+	// short secrets are still accepted
+	config.AuthSecret = "short"
+	assert.NoError(t, config.Validate(RequireAuth))
+	assert.Contains(t, config.Warnings(), "AUTH_SECRET")
+}
+
+func TestRequireIndexer(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.IndexerChainID = 42161
+	config.IndexerContracts = []domain.EthereumAddress{domain.EthereumAddress(tester.GenerateAddress(t))}
+
+	err := config.Validate(RequireIndexer)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CHAIN_RPC_URLS")
+
+	// indexing another chain doesn't need a mainnet node
+	config.EthereumRPCURL = ""
+	config.ChainRPCURLs = chain.URLs{42161: "http://localhost:8546"}
+	assert.NoError(t, config.Validate(RequireIndexer))
+}
+
+func TestRequireTokenGate(t *testing.T) {
+	t.Parallel()
+
+	// the gate is off by default
+	config := validTestConfig()
+	assert.NoError(t, config.Validate(RequireTokenGate))
+
+	config.TokenGateContract = domain.EthereumAddress(tester.GenerateAddress(t))
+	config.SupportedChainIDs = []domain.ChainID{domain.ChainIDMainnet, 42161}
+	assert.NoError(t, config.Validate(RequireTokenGate))
+
+	// reading from the chain needs a node for every chain users sign in with
+	config.HoldingSource = HoldingSourceChain
+	err := config.Validate(RequireTokenGate)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CHAIN_RPC_URLS")
+
+	config.ChainRPCURLs = chain.URLs{42161: "http://localhost:8546"}
+	assert.NoError(t, config.Validate(RequireTokenGate))
+	assert.Equal(t, chain.URLs{domain.ChainIDMainnet: "http://localhost:8545", 42161: "http://localhost:8546"}, config.RPCURLs())
+
+	config.HoldingSource = "cache"
+	err = config.Validate(RequireTokenGate)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HOLDING_SOURCE")
+}
+
+func TestConfig_ValidateSSH(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.SSHHost = "bastion.example.com"
+
+	err := config.Validate()
+	require.Error(t, err)
+	for _, key := range []string{"SSH_USER", "SSH_KEY_FILE", "SSH_KNOWN_HOSTS_FILE"} {
+		assert.Contains(t, err.Error(), key)
+	}
+
+	config.SSHUser = "ec2-user"
+	config.SSHUseAgent = true
+	config.SSHHostKeyFingerprint = "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+	assert.NoError(t, config.Validate())
+}
+
+func TestConfig_DBPool(t *testing.T) {
+	t.Parallel()
+
+	var config Config
+	require.NoError(t, loadConfig(&config, map[string]string{}, helpers.LoadSecrets))
+	assert.Equal(t, db.LambdaPoolConfig, config.DBPool())
+
+	// 0 is a setting, not a missing one
+	require.NoError(t, loadConfig(&config, map[string]string{
+		"DB_MAX_OPEN_CONNS":            "10",
+		"DB_MAX_IDLE_CONNS":            "0",
+		"DB_CONN_MAX_LIFETIME_SECONDS": "0",
+		"DB_STALE_AFTER_SECONDS":       "0",
+	}, helpers.LoadSecrets))
+
+	pool := config.DBPool()
+	assert.Equal(t, 10, pool.MaxOpenConns)
+	assert.Equal(t, 0, pool.MaxIdleConns)
+	assert.Equal(t, time.Duration(0), pool.ConnMaxLifetime)
+	assert.Equal(t, db.LambdaPoolConfig.ConnectTimeout, pool.ConnectTimeout)
+	assert.Equal(t, time.Duration(0), pool.StaleAfter)
+}
+
+func TestConfig_LogConfig(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.LogsRedactFields = []string{"profile.email"}
+	config.LogsRedactPatterns = []string{`sk_live_[0-9a-z]+`}
+	config.LogsSuccessSampleRate = 0.1
+
+	logConfig, err := config.LogConfig()
+	require.NoError(t, err)
+
+	// the defaults are kept
+	assert.Equal(t, append(server.DefaultLogConfig.RedactFields, "profile.email"), logConfig.RedactFields)
+	assert.Len(t, logConfig.RedactPatterns, len(server.DefaultLogConfig.RedactPatterns)+1)
+	assert.Len(t, server.DefaultLogConfig.RedactPatterns, 2)
+	assert.Equal(t, 0.1, logConfig.SuccessSampleRate)
+
+	config.LogsRedactPatterns = []string{"(unclosed"}
+	config.LogsSuccessSampleRate = 2
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LOGS_REDACT_PATTERNS")
+	assert.Contains(t, err.Error(), "LOGS_SUCCESS_SAMPLE_RATE")
+}
+
+func TestConfig_Tracing(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.TracingExporter = tracing.ExporterOTLP
+	config.TracingXRay = true
+	config.DopplerEnvironment = "prd"
+	require.NoError(t, config.Validate())
+
+	tracingConfig := config.Tracing()
+	assert.Equal(t, tracing.ExporterOTLP, tracingConfig.Exporter)
+	assert.True(t, tracingConfig.XRay)
+	assert.Equal(t, "prd", tracingConfig.Environment)
+
+	config.TracingExporter = "zipkin"
+	config.TracingSampleRate = -1
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRACING_EXPORTER")
+	assert.Contains(t, err.Error(), "TRACING_SAMPLE_RATE")
+}
+
+func TestRequirePrometheus(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.MetricsBackend = server.MetricsBackendEMF
+	require.NoError(t, config.Validate())
+
+	err := config.Validate(RequirePrometheus)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "METRICS_BACKEND")
+
+	config.MetricsBackend = server.MetricsBackendPrometheus
+	assert.NoError(t, config.Validate(RequirePrometheus))
+
+	config.MetricsBackend = "statsd"
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "METRICS_BACKEND")
+}
+
+func TestConfig_CORS(t *testing.T) {
+	t.Parallel()
+
+	config := validTestConfig()
+	config.CORSAllowOrigins = []string{"https://app.example.com", "https://*.preview.example.com"}
+	config.CORSAllowHeaders = []string{"Authorization", "Content-Type"}
+	config.CORSAllowCredentials = true
+	config.APIStages = []string{"prod", "staging"}
+	require.NoError(t, config.Validate())
+
+	cors := config.CORS()
+	assert.Equal(t, config.CORSAllowOrigins, cors.AllowOrigins)
+	assert.Equal(t, []string{"Authorization", "Content-Type"}, cors.AllowHeaders)
+	assert.True(t, cors.AllowCredentials)
+	// the methods left unset fall back to the defaults
+	assert.Equal(t, server.DefaultCORSConfig.AllowMethods, cors.AllowMethods)
+
+	config.CORSAllowOrigins = []string{"*", "app.example.com"}
+	config.CORSAllowMethods = []string{"GET", "FETCH"}
+	config.CORSAllowHeaders = []string{"X Custom"}
+	config.APIStages = []string{"prod/v1"}
+	err := config.Validate()
+	require.Error(t, err)
+	for _, key := range []string{"CORS_ALLOW_ORIGINS", "CORS_ALLOW_METHODS", "CORS_ALLOW_HEADERS", "CORS_ALLOW_CREDENTIALS", "API_STAGES"} {
+		assert.Contains(t, err.Error(), key)
+	}
+}
+
+func TestLoadConfig_CORS(t *testing.T) {
+	t.Parallel()
+
+	var config Config
+	require.NoError(t, loadConfig(&config, map[string]string{
+		"CORS_ALLOW_ORIGINS": "https://app.example.com,https://*.example.com",
+		"API_STAGES":         "",
+	}, nil))
+
+	assert.Equal(t, []string{"https://app.example.com", "https://*.example.com"}, config.CORSAllowOrigins)
+	// templates declare the variable empty, the default still applies
+	assert.Equal(t, []string{"prod", "dev"}, config.APIStages)
+}
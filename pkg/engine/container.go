@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"github.com/manta-coder/golang-serverless-example/pkg/ens"
+	"github.com/manta-coder/golang-serverless-example/pkg/service"
+	"github.com/manta-coder/golang-serverless-example/pkg/store"
+	"time"
+)
+
+// Container builds the stores and services shared by modules. Each dependency is built on first use and then
+// reused, so modules mounted together share the same instances
+type Container struct {
+	Config Config
+	Server *Server
+
+	resolver       ens.Resolver
+	userStore      store.UserStore
+	challengeStore store.ChallengeStore
+	ownershipStore store.OwnershipStore
+	userService    service.UserService
+	authService    service.AuthService
+	holdingService service.HoldingService
+}
+
+func NewContainer(config Config, server *Server) *Container {
+	return &Container{Config: config, Server: server}
+}
+
+// MustContainer creates a server and its container or panics if there's an error
+func MustContainer(config Config) *Container {
+	return NewContainer(config, MustServer(config))
+}
+
+// Resolver resolves ens names with the node of ETHEREUM_RPC_URL, it only connects on the first resolution
+func (c *Container) Resolver() ens.Resolver {
+	if c.resolver == nil {
+		c.resolver = ens.NewLazyRPCResolver(c.Config.EthereumRPCURL)
+	}
+
+	return c.resolver
+}
+
+func (c *Container) UserStore() store.UserStore {
+	if c.userStore == nil {
+		c.userStore = store.NewUserStore(c.Server.Logger, c.Server.DB)
+	}
+
+	return c.userStore
+}
+
+func (c *Container) ChallengeStore() store.ChallengeStore {
+	if c.challengeStore == nil {
+		c.challengeStore = store.NewChallengeStore(c.Server.Logger, c.Server.DB)
+	}
+
+	return c.challengeStore
+}
+
+func (c *Container) OwnershipStore() store.OwnershipStore {
+	if c.ownershipStore == nil {
+		c.ownershipStore = store.NewOwnershipStore(c.Server.Logger, c.Server.DB)
+	}
+
+	return c.ownershipStore
+}
+
+func (c *Container) UserService() service.UserService {
+	if c.userService == nil {
+		c.userService = service.NewTracedUserService(service.NewUserService(c.Server.Logger, c.UserStore(), c.Resolver()))
+	}
+
+	return c.userService
+}
+
+func (c *Container) AuthService() service.AuthService {
+	if c.authService == nil {
+		ted := time.Duration(c.Config.AuthTokenExpiryDurationSeconds) * time.Second
+		authentication := auth.NewService(c.Config.AuthSecret, ted, c.Config.SupportedChainIDs...)
+
+		c.authService = service.NewTracedAuthService(service.NewAuthService(c.Server.Logger, authentication, c.ChallengeStore(), c.UserService(), c.Server.Metrics))
+	}
+
+	return c.authService
+}
+
+// HoldingService checks holdings with the source picked by HOLDING_SOURCE
+func (c *Container) HoldingService() (service.HoldingService, error) {
+	if c.holdingService == nil {
+		source := service.NewIndexedHoldingSource(c.OwnershipStore())
+
+		if c.Config.HoldingSource == HoldingSourceChain {
+			readers, err := chain.DialReaders(context.Background(), c.Config.RPCURLs())
+			if err != nil {
+				return nil, fmt.Errorf("unable to connect to the chain nodes: %w", err)
+			}
+
+			source = service.NewChainHoldingSource(readers)
+		}
+
+		c.holdingService = service.NewHoldingService(c.Server.Logger, source)
+	}
+
+	return c.holdingService, nil
+}
@@ -1,44 +1,329 @@
 package engine
 
 import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"github.com/manta-coder/golang-serverless-example/pkg/controller"
 	"github.com/manta-coder/golang-serverless-example/pkg/db"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
 	"github.com/manta-coder/golang-serverless-example/pkg/server"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"github.com/manta-coder/golang-serverless-example/pkg/version"
 	"go.uber.org/zap"
+	"os"
+	"regexp"
+	"time"
 )
 
 type Config struct {
-	DBHost                         string `env:"DB_HOST"`
-	DBPort                         string `env:"DB_PORT"`
-	DBName                         string `env:"DB_NAME"`
-	DBUser                         string `env:"DB_USER"`
-	DBPass                         string `env:"DB_PASS"`
-	LogsDebug                      bool   `env:"LOGS_DEBUG"`
-	AuthTokenExpiryDurationSeconds int    `env:"AUTH_TOKEN_EXPIRY_DURATION_SECONDS"`
-	AuthSecret                     string `env:"AUTH_SECRET"`
-	FrontEndDomain                 string `env:"FRONT_END_DOMAIN"`
-	DopplerEnvironment             string `env:"DOPPLER_ENVIRONMENT"`
+	DBHost                         string                   `env:"DB_HOST"`
+	DBPort                         string                   `env:"DB_PORT"`
+	DBName                         string                   `env:"DB_NAME"`
+	DBUser                         string                   `env:"DB_USER"`
+	DBPass                         string                   `env:"DB_PASS"`
+	LogsDebug                      bool                     `env:"LOGS_DEBUG"`
+	LogsRedactFields               []string                 `env:"LOGS_REDACT_FIELDS"`
+	LogsRedactHeaders              []string                 `env:"LOGS_REDACT_HEADERS"`
+	LogsRedactForm                 []string                 `env:"LOGS_REDACT_FORM"`
+	LogsRedactPatterns             []string                 `env:"LOGS_REDACT_PATTERNS"`
+	LogsMaxBodyBytes               int                      `env:"LOGS_MAX_BODY_BYTES" envDefault:"4096"`
+	LogsOmitBodyRoutes             []string                 `env:"LOGS_OMIT_BODY_ROUTES"`
+	LogsSkipRoutes                 []string                 `env:"LOGS_SKIP_ROUTES"`
+	LogsSuccessSampleRate          float64                  `env:"LOGS_SUCCESS_SAMPLE_RATE" envDefault:"1"`
+	TracingExporter                string                   `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingEndpoint                string                   `env:"TRACING_ENDPOINT"`
+	TracingInsecure                bool                     `env:"TRACING_INSECURE"`
+	TracingSampleRate              float64                  `env:"TRACING_SAMPLE_RATE" envDefault:"1"`
+	TracingXRay                    bool                     `env:"TRACING_XRAY"`
+	MetricsBackend                 string                   `env:"METRICS_BACKEND" envDefault:"emf"`
+	MetricsNamespace               string                   `env:"METRICS_NAMESPACE" envDefault:"golang-serverless-example"`
+	AuthTokenExpiryDurationSeconds int                      `env:"AUTH_TOKEN_EXPIRY_DURATION_SECONDS"`
+	AuthSecret                     string                   `env:"AUTH_SECRET"`
+	APIStages                      []string                 `env:"API_STAGES" envDefault:"prod,dev"`
+	CORSAllowOrigins               []string                 `env:"CORS_ALLOW_ORIGINS"`
+	CORSAllowMethods               []string                 `env:"CORS_ALLOW_METHODS"`
+	CORSAllowHeaders               []string                 `env:"CORS_ALLOW_HEADERS"`
+	CORSAllowCredentials           bool                     `env:"CORS_ALLOW_CREDENTIALS"`
+	DopplerEnvironment             string                   `env:"DOPPLER_ENVIRONMENT"`
+	EthereumRPCURL                 string                   `env:"ETHEREUM_RPC_URL"`
+	IndexerContracts               []domain.EthereumAddress `env:"INDEXER_CONTRACTS"`
+	IndexerCharacterContract       domain.EthereumAddress   `env:"INDEXER_CHARACTER_CONTRACT"`
+	IndexerStartBlock              uint64                   `env:"INDEXER_START_BLOCK"`
+	IndexerBatchSize               uint64                   `env:"INDEXER_BATCH_SIZE" envDefault:"1000"`
+	IndexerConfirmations           uint64                   `env:"INDEXER_CONFIRMATIONS" envDefault:"12"`
+	EnsRefreshMaxAgeSeconds        int                      `env:"ENS_REFRESH_MAX_AGE_SECONDS" envDefault:"86400"`
+	EnsRefreshBatchSize            uint64                   `env:"ENS_REFRESH_BATCH_SIZE" envDefault:"100"`
+	SupportedChainIDs              []domain.ChainID         `env:"SUPPORTED_CHAIN_IDS" envDefault:"1"`
+	ChainRPCURLs                   chain.URLs               `env:"CHAIN_RPC_URLS"`
+	IndexerChainID                 domain.ChainID           `env:"INDEXER_CHAIN_ID" envDefault:"1"`
+	HoldingSource                  string                   `env:"HOLDING_SOURCE" envDefault:"indexed"`
+	TokenGateContract              domain.EthereumAddress   `env:"TOKEN_GATE_CONTRACT"`
+	TokenGateMinBalance            uint64                   `env:"TOKEN_GATE_MIN_BALANCE" envDefault:"1"`
+	DBMaxOpenConns                 *int                     `env:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns                 *int                     `env:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetimeSeconds       *int                     `env:"DB_CONN_MAX_LIFETIME_SECONDS"`
+	DBConnectTimeoutSeconds        *int                     `env:"DB_CONNECT_TIMEOUT_SECONDS"`
+	DBStaleAfterSeconds            *int                     `env:"DB_STALE_AFTER_SECONDS"`
+	DBSchemaVersion                uint64                   `env:"DB_SCHEMA_VERSION"`
+	DBAuth                         string                   `env:"DB_AUTH" envDefault:"password"`
+	AWSRegion                      string                   `env:"AWS_REGION"`
+	SSHHost                        string                   `env:"SSH_HOST"`
+	SSHPort                        string                   `env:"SSH_PORT" envDefault:"22"`
+	SSHUser                        string                   `env:"SSH_USER"`
+	SSHKeyFile                     string                   `env:"SSH_KEY_FILE"`
+	SSHKeyPassphrase               string                   `env:"SSH_KEY_PASSPHRASE"`
+	SSHUseAgent                    bool                     `env:"SSH_USE_AGENT"`
+	SSHKnownHostsFile              string                   `env:"SSH_KNOWN_HOSTS_FILE"`
+	SSHHostKeyFingerprint          string                   `env:"SSH_HOST_KEY_FINGERPRINT"`
+	SSHKeepAliveSeconds            int                      `env:"SSH_KEEPALIVE_SECONDS" envDefault:"30"`
 }
 
+// DBPool returns the pool settings, falling back to db.LambdaPoolConfig for the ones left unset. 0 is a setting, see
+// db.PoolConfig
+func (config Config) DBPool() db.PoolConfig {
+	pool := db.LambdaPoolConfig
+
+	if config.DBMaxOpenConns != nil {
+		pool.MaxOpenConns = *config.DBMaxOpenConns
+	}
+	if config.DBMaxIdleConns != nil {
+		pool.MaxIdleConns = *config.DBMaxIdleConns
+	}
+	if config.DBConnMaxLifetimeSeconds != nil {
+		pool.ConnMaxLifetime = time.Duration(*config.DBConnMaxLifetimeSeconds) * time.Second
+	}
+	if config.DBConnectTimeoutSeconds != nil {
+		pool.ConnectTimeout = time.Duration(*config.DBConnectTimeoutSeconds) * time.Second
+	}
+	if config.DBStaleAfterSeconds != nil {
+		pool.StaleAfter = time.Duration(*config.DBStaleAfterSeconds) * time.Second
+	}
+
+	return pool
+}
+
+// SSHTunnel opens the tunnel to the database described by the SSH_ settings, it returns nil when SSH_HOST is unset
+func (config Config) SSHTunnel() (*db.Tunnel, error) {
+	if config.SSHHost == "" {
+		return nil, nil
+	}
+
+	sshConfig := db.SSHConfig{
+		User:               config.SSHUser,
+		Host:               config.SSHHost,
+		Port:               config.SSHPort,
+		KeyPassphrase:      []byte(config.SSHKeyPassphrase),
+		UseAgent:           config.SSHUseAgent,
+		KnownHostsFile:     config.SSHKnownHostsFile,
+		HostKeyFingerprint: config.SSHHostKeyFingerprint,
+		KeepAliveInterval:  time.Duration(config.SSHKeepAliveSeconds) * time.Second,
+	}
+
+	if config.SSHKeyFile != "" {
+		key, err := os.ReadFile(config.SSHKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ssh key: %w", err)
+		}
+		sshConfig.Key = key
+	}
+
+	return db.NewTunnel(sshConfig)
+}
+
+// LogConfig returns the request logging settings. Redactions add to server.DefaultLogConfig, they can't remove its
+// own
+func (config Config) LogConfig() (server.LogConfig, error) {
+	logConfig := server.DefaultLogConfig
+
+	logConfig.RedactFields = concat(logConfig.RedactFields, config.LogsRedactFields)
+	logConfig.RedactHeaders = concat(logConfig.RedactHeaders, config.LogsRedactHeaders)
+	logConfig.RedactForm = concat(logConfig.RedactForm, config.LogsRedactForm)
+
+	patterns := append([]*regexp.Regexp{}, logConfig.RedactPatterns...)
+	for _, expr := range config.LogsRedactPatterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return server.LogConfig{}, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	logConfig.RedactPatterns = patterns
+
+	logConfig.MaxBodySize = config.LogsMaxBodyBytes
+	logConfig.OmitBodies = config.LogsOmitBodyRoutes
+	logConfig.SkipRoutes = config.LogsSkipRoutes
+	logConfig.SuccessSampleRate = config.LogsSuccessSampleRate
+
+	return logConfig, nil
+}
+
+// Tracing returns where spans go
+func (config Config) Tracing() tracing.Config {
+	return tracing.Config{
+		Exporter:    config.TracingExporter,
+		Endpoint:    config.TracingEndpoint,
+		Insecure:    config.TracingInsecure,
+		SampleRate:  config.TracingSampleRate,
+		XRay:        config.TracingXRay,
+		Environment: config.DopplerEnvironment,
+	}
+}
+
+// CORS returns which origins may call the API, falling back to server.DefaultCORSConfig for the methods and headers
+// left unset
+func (config Config) CORS() server.CORSConfig {
+	cors := server.DefaultCORSConfig
+
+	cors.AllowOrigins = config.CORSAllowOrigins
+	if len(config.CORSAllowMethods) > 0 {
+		cors.AllowMethods = config.CORSAllowMethods
+	}
+	if len(config.CORSAllowHeaders) > 0 {
+		cors.AllowHeaders = config.CORSAllowHeaders
+	}
+	cors.AllowCredentials = config.CORSAllowCredentials
+
+	return cors
+}
+
+// concat returns a new slice so appending never writes to the backing array of a
+func concat(a []string, b []string) []string {
+	return append(append([]string{}, a...), b...)
+}
+
+// RPCURL returns the url of the node of chainID. Mainnet falls back to ETHEREUM_RPC_URL
+func (config Config) RPCURL(chainID domain.ChainID) string {
+	if url, ok := config.ChainRPCURLs[chainID]; ok {
+		return url
+	}
+	if chainID == domain.ChainIDMainnet {
+		return config.EthereumRPCURL
+	}
+
+	return ""
+}
+
+// RPCURLs returns the urls of the nodes of the supported chains that have one
+func (config Config) RPCURLs() chain.URLs {
+	urls := chain.URLs{}
+	for _, chainID := range config.SupportedChainIDs {
+		if url := config.RPCURL(chainID); url != "" {
+			urls[chainID] = url
+		}
+	}
+
+	return urls
+}
+
+const (
+	DBAuthPassword = "password"
+	DBAuthIAM      = "iam"
+)
+
+const (
+	// HoldingSourceIndexed reads holdings from the ownerships of the indexer
+	HoldingSourceIndexed = "indexed"
+	// HoldingSourceChain reads holdings from the nodes of CHAIN_RPC_URLS
+	HoldingSourceChain = "chain"
+)
+
 type Server struct {
 	Echo   *echo.Echo
 	Logger *zap.SugaredLogger
 	DB     *sqlx.DB
+	// Tunnel is set when the database is reached through ssh
+	Tunnel *db.Tunnel
+	// Tracing exports the spans of the server
+	Tracing *tracing.Provider
+	Metrics server.Metrics
+}
+
+// Close closes the database and the tunnel it goes through, then exports the remaining spans
+func (s *Server) Close() error {
+	err := s.DB.Close()
+	if s.Tunnel != nil {
+		_ = s.Tunnel.Close()
+	}
+	_ = s.Tracing.Shutdown(context.Background())
+
+	return err
 }
 
 // MustServer creates a server or panics if there's an error
 func MustServer(config Config) *Server {
 	// initialize loggers
 	logger := helpers.NewLogger(config.LogsDebug)
+	for key, err := range config.Warnings() {
+		logger.Warnw("weak config", "key", key, "err", err)
+	}
+
+	// set up before the database and echo, their instrumentations use the global provider
+	provider, err := tracing.Setup(context.Background(), config.Tracing())
+	if err != nil {
+		logger.Fatalw("unable to set up tracing", "err", err)
+	}
+
+	// lambdas write metrics to their logs, CloudWatch extracts them
+	metrics, err := server.NewMetrics(config.MetricsBackend, config.MetricsNamespace, os.Stdout)
+	if err != nil {
+		logger.Fatalw("unable to set up metrics", "err", err)
+	}
+
+	dbConfig := db.Config{
+		Host:     config.DBHost,
+		Port:     config.DBPort,
+		User:     config.DBUser,
+		Pass:     config.DBPass,
+		Database: config.DBName,
+		Pool:     config.DBPool(),
+		Metrics:  metrics,
+	}
+
+	switch config.DBAuth {
+	case "", DBAuthPassword:
+	case DBAuthIAM:
+		creds := session.Must(session.NewSession()).Config.Credentials
+		signer := db.NewRDSTokenSigner(config.DBHost, config.DBPort, config.AWSRegion, config.DBUser, creds)
+		dbConfig.TokenProvider = db.NewTokenProvider(signer)
+	default:
+		logger.Fatalw("unknown database auth", "auth", config.DBAuth)
+	}
+
+	tunnel, err := config.SSHTunnel()
+	if err != nil {
+		logger.Fatalw("unable to open ssh tunnel", "err", err)
+	}
+	dbConfig.Tunnel = tunnel
+
+	// the connection is made on the first query, so instances that don't need the database never wait for it
+	sql, err := db.Open(dbConfig)
+	if err != nil {
+		logger.Fatalw("unable to configure database", "err", err)
+	}
 
-	sql, err := db.Postgres(config.DBHost, config.DBPort, config.DBUser, config.DBPass, config.DBName)
+	logConfig, err := config.LogConfig()
 	if err != nil {
-		logger.Fatalw("unable to connect to database", "err", err)
+		logger.Fatalw("invalid log config", "err", err)
+	}
+
+	e := server.NewEcho(logger, logConfig, metrics, config.CORS(), config.APIStages...)
+
+	// every instance answers probes, whatever modules it mounts
+	checks := []controller.Check{{Name: "database", Run: sql.PingContext}}
+
+	// migrations are applied outside of the service, the deployment tells which schema version it applied
+	if config.DBSchemaVersion > 0 {
+		checks = append(checks, controller.Check{Name: "migrations", Run: func(ctx context.Context) error {
+			return db.CheckSchema(ctx, sql, config.DBSchemaVersion)
+		}})
 	}
 
-	e := server.NewEcho(logger, config.FrontEndDomain)
+	controller.NewHealthController(e, logger, version.Get(config.DopplerEnvironment), config.DBPool().ConnectTimeout, checks...)
 
-	return &Server{e, logger, sql}
+	return &Server{e, logger, sql, tunnel, provider, metrics}
 }
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	echoadapter "github.com/awslabs/aws-lambda-go-api-proxy/echo"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+)
+
+// ErrUnsupportedEvent is returned for payloads that aren't an API Gateway or ALB request
+var ErrUnsupportedEvent = errors.New("unsupported event")
+
+// EventSource is the service that invoked the lambda
+type EventSource string
+
+const (
+	EventSourceAPIGatewayV1 EventSource = "apigateway-v1"
+	EventSourceAPIGatewayV2 EventSource = "apigateway-v2"
+	EventSourceALB          EventSource = "alb"
+)
+
+// eventShape holds the fields telling events apart
+type eventShape struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB *struct{} `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// DetectEventSource returns the source of a lambda payload
+func DetectEventSource(payload []byte) (EventSource, error) {
+	var shape eventShape
+	if err := json.Unmarshal(payload, &shape); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedEvent, err)
+	}
+
+	switch {
+	case shape.RequestContext.ELB != nil:
+		return EventSourceALB, nil
+	case shape.Version == "2.0":
+		return EventSourceAPIGatewayV2, nil
+	case shape.HTTPMethod != "":
+		// REST APIs send version 1.0 when they send one at all
+		return EventSourceAPIGatewayV1, nil
+	default:
+		return "", ErrUnsupportedEvent
+	}
+}
+
+// Handler serves API Gateway REST (v1), API Gateway HTTP (v2) and ALB events with the same echo instance
+type Handler struct {
+	v1 *echoadapter.EchoLambda
+	v2 *echoadapter.EchoLambdaV2
+}
+
+func NewHandler(e *echo.Echo) *Handler {
+	return &Handler{echoadapter.New(e), echoadapter.NewV2(e)}
+}
+
+// Invoke implements lambda.Handler
+func (h *Handler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	source, err := DetectEventSource(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var response interface{}
+
+	switch source {
+	case EventSourceAPIGatewayV1:
+		var req events.APIGatewayProxyRequest
+		if err = json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		response, err = h.v1.ProxyWithContext(ctx, req)
+	case EventSourceAPIGatewayV2:
+		var req events.APIGatewayV2HTTPRequest
+		if err = json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		response, err = h.v2.ProxyWithContext(ctx, req)
+	case EventSourceALB:
+		var req events.ALBTargetGroupRequest
+		if err = json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		response, err = h.proxyALB(ctx, req)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+// proxyALB serves an ALB request through the REST adapter, the two events only differ by a few fields
+func (h *Handler) proxyALB(ctx context.Context, req events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	// unlike API Gateway, ALB forwards query strings as they were sent, still url encoded
+	query, err := unescapeQuery(req.QueryStringParameters)
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+
+	multiValueQuery, err := unescapeMultiValueQuery(req.MultiValueQueryStringParameters)
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+
+	host := req.Headers["host"]
+	if values := req.MultiValueHeaders["host"]; len(values) > 0 {
+		host = values[0]
+	}
+
+	res, err := h.v1.ProxyWithContext(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:                      req.HTTPMethod,
+		Path:                            req.Path,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiValueQuery,
+		Headers:                         req.Headers,
+		MultiValueHeaders:               req.MultiValueHeaders,
+		IsBase64Encoded:                 req.IsBase64Encoded,
+		Body:                            req.Body,
+		RequestContext:                  events.APIGatewayProxyRequestContext{DomainName: host},
+	})
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+
+	response := events.ALBTargetGroupResponse{
+		StatusCode:        res.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		Body:              res.Body,
+		IsBase64Encoded:   res.IsBase64Encoded,
+	}
+
+	// the load balancer expects multi-value headers back only when it sent them
+	if req.MultiValueHeaders != nil {
+		response.MultiValueHeaders = res.MultiValueHeaders
+	} else {
+		response.Headers = map[string]string{}
+		for key, values := range res.MultiValueHeaders {
+			response.Headers[key] = values[len(values)-1]
+		}
+	}
+
+	return response, nil
+}
+
+func unescapeQuery(query map[string]string) (map[string]string, error) {
+	if query == nil {
+		return nil, nil
+	}
+
+	result := map[string]string{}
+	for key, value := range query {
+		k, v, err := unescapePair(key, value)
+		if err != nil {
+			return nil, err
+		}
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+func unescapeMultiValueQuery(query map[string][]string) (map[string][]string, error) {
+	if query == nil {
+		return nil, nil
+	}
+
+	result := map[string][]string{}
+	for key, values := range query {
+		for _, value := range values {
+			k, v, err := unescapePair(key, value)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = append(result[k], v)
+		}
+	}
+
+	return result, nil
+}
+
+func unescapePair(key string, value string) (string, string, error) {
+	k, err := url.QueryUnescape(key)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid query parameter %q: %w", key, err)
+	}
+	v, err := url.QueryUnescape(value)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid query parameter %q: %w", key, err)
+	}
+
+	return k, v, nil
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func newTestHandler() *Handler {
+	e := echo.New()
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{
+			"id":   c.Param("id"),
+			"name": c.QueryParam("name"),
+			"test": c.Request().Header.Get("X-Test"),
+		})
+	})
+
+	return NewHandler(e)
+}
+
+func readEvent(t *testing.T, name string) []byte {
+	t.Helper()
+
+	payload, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	return payload
+}
+
+func TestDetectEventSource(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]EventSource{
+		"apigateway_v1.json":   EventSourceAPIGatewayV1,
+		"apigateway_v2.json":   EventSourceAPIGatewayV2,
+		"alb.json":             EventSourceALB,
+		"alb_multi_value.json": EventSourceALB,
+	} {
+		source, err := DetectEventSource(readEvent(t, name))
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, source, name)
+	}
+
+	_, err := DetectEventSource([]byte(`{"source":"aws.events","detail-type":"Scheduled Event"}`))
+	assert.ErrorIs(t, err, ErrUnsupportedEvent)
+
+	_, err = DetectEventSource([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrUnsupportedEvent)
+}
+
+func TestHandler_Invoke(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler()
+	ctx := context.Background()
+
+	t.Run("apigateway v1", func(t *testing.T) {
+		payload, err := handler.Invoke(ctx, readEvent(t, "apigateway_v1.json"))
+		require.NoError(t, err)
+
+		var res events.APIGatewayProxyResponse
+		require.NoError(t, json.Unmarshal(payload, &res))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"id":"usr_1","name":"foo bar","test":"v1"}`, res.Body)
+	})
+
+	t.Run("apigateway v2", func(t *testing.T) {
+		payload, err := handler.Invoke(ctx, readEvent(t, "apigateway_v2.json"))
+		require.NoError(t, err)
+
+		var res events.APIGatewayV2HTTPResponse
+		require.NoError(t, json.Unmarshal(payload, &res))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"id":"usr_1","name":"foo bar","test":"v2"}`, res.Body)
+	})
+
+	t.Run("alb", func(t *testing.T) {
+		payload, err := handler.Invoke(ctx, readEvent(t, "alb.json"))
+		require.NoError(t, err)
+
+		var res events.ALBTargetGroupResponse
+		require.NoError(t, json.Unmarshal(payload, &res))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "200 OK", res.StatusDescription)
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, res.Headers[echo.HeaderContentType])
+		assert.Nil(t, res.MultiValueHeaders)
+		assert.JSONEq(t, `{"id":"usr_1","name":"foo bar","test":"alb"}`, res.Body)
+	})
+
+	t.Run("alb multi value", func(t *testing.T) {
+		payload, err := handler.Invoke(ctx, readEvent(t, "alb_multi_value.json"))
+		require.NoError(t, err)
+
+		var res events.ALBTargetGroupResponse
+		require.NoError(t, json.Unmarshal(payload, &res))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{echo.MIMEApplicationJSONCharsetUTF8}, res.MultiValueHeaders[echo.HeaderContentType])
+		assert.Nil(t, res.Headers)
+		assert.JSONEq(t, `{"id":"usr_1","name":"foo bar","test":"alb"}`, res.Body)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := handler.Invoke(ctx, []byte(`{}`))
+		assert.ErrorIs(t, err, ErrUnsupportedEvent)
+	})
+}
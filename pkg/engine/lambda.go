@@ -0,0 +1,13 @@
+package engine
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
+)
+
+// Lambda boots modules from the loaded config and serves them to API Gateway or an ALB
+func Lambda(modules ...Module) {
+	c := MustBoot(MustLoadConfig(Requirements(modules...)...), modules...)
+
+	lambda.StartHandler(server.MetricsHandler(c.Server.Metrics, c.Server.Tracing.Handler(NewHandler(c.Server.Echo))))
+}
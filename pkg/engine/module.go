@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Module is a subsystem exposing routes. It takes its stores and services from the container instead of building
+// them, so every entry point mounting it is wired the same way
+type Module struct {
+	Name  string
+	Mount func(c *Container) error
+	// Requires checks the config the module needs, it's optional
+	Requires Requirement
+}
+
+var registry []Module
+
+// Register makes a module available to Modules. Modules register themselves once, in init
+func Register(module Module) Module {
+	for _, registered := range registry {
+		if registered.Name == module.Name {
+			panic(fmt.Errorf("module %s is already registered", module.Name))
+		}
+	}
+
+	registry = append(registry, module)
+
+	return module
+}
+
+// Modules returns every registered module in registration order
+func Modules() []Module {
+	return append([]Module{}, registry...)
+}
+
+// Mount mounts modules in order
+func (c *Container) Mount(modules ...Module) error {
+	for _, module := range modules {
+		if err := module.Mount(c); err != nil {
+			return fmt.Errorf("unable to mount module %s: %w", module.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Requirements returns the config requirements of modules
+func Requirements(modules ...Module) []Requirement {
+	var requirements []Requirement
+	for _, module := range modules {
+		if module.Requires != nil {
+			requirements = append(requirements, module.Requires)
+		}
+	}
+
+	return requirements
+}
+
+// MustBoot creates a container with modules mounted or exits if there's an error
+func MustBoot(config Config, modules ...Module) *Container {
+	c := MustContainer(config)
+
+	if err := c.Mount(modules...); err != nil {
+		c.Server.Logger.Fatalw("unable to mount modules", "err", err)
+	}
+
+	return c
+}
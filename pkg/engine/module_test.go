@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	var names []string
+	for _, module := range Modules() {
+		names = append(names, module.Name)
+	}
+
+	assert.Contains(t, names, UsersModule.Name)
+	assert.Contains(t, names, AuthModule.Name)
+
+	assert.Panics(t, func() {
+		Register(Module{Name: UsersModule.Name})
+	})
+}
+
+func TestContainer_Mount(t *testing.T) {
+	t.Parallel()
+
+	var mounted []string
+	module := func(name string, err error) Module {
+		return Module{Name: name, Mount: func(c *Container) error {
+			mounted = append(mounted, name)
+			return err
+		}}
+	}
+
+	c := NewContainer(Config{}, nil)
+
+	require.NoError(t, c.Mount(module("a", nil), module("b", nil)))
+	assert.Equal(t, []string{"a", "b"}, mounted)
+
+	// mounting stops at the first failing module
+	errMount := errors.New("mount failed")
+	err := c.Mount(module("c", errMount), module("d", nil))
+	assert.ErrorIs(t, err, errMount)
+	assert.Equal(t, []string{"a", "b", "c"}, mounted)
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/controller"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"net/http"
+)
+
+// UsersModule serves /users
+var UsersModule = Register(Module{
+	Name:     "users",
+	Requires: Requires(RequireAuth, RequireEns, RequireTokenGate),
+	Mount: func(c *Container) error {
+		middlewares := []echo.MiddlewareFunc{
+			controller.NewAuthenticator(c.Config.AuthSecret),
+			controller.NewAuthMiddleware(),
+		}
+
+		group := c.Server.Echo.Group("/users", middlewares...)
+		controller.NewUserController(group, c.Server.Logger, c.UserService())
+
+		if c.Config.TokenGateContract != (domain.EthereumAddress{}) {
+			holdingService, err := c.HoldingService()
+			if err != nil {
+				return err
+			}
+
+			controller.NewMembershipController(group, holdingService, c.Config.TokenGateContract, c.Config.TokenGateMinBalance)
+		}
+
+		return nil
+	},
+})
+
+// AuthModule serves /auth
+var AuthModule = Register(Module{
+	Name:     "auth",
+	Requires: Requires(RequireAuth, RequireEns),
+	Mount: func(c *Container) error {
+		authenticator := controller.NewAuthenticator(c.Config.AuthSecret)
+
+		group := c.Server.Echo.Group("/auth")
+		controller.NewAuthController(group, c.Server.Logger, c.AuthService(), authenticator)
+
+		return nil
+	},
+})
+
+// ErrorsModule serves /errors, the catalog of error codes
+var ErrorsModule = Register(Module{
+	Name: "errors",
+	Mount: func(c *Container) error {
+		controller.NewErrorsController(c.Server.Echo.Group("/errors"))
+
+		return nil
+	},
+})
+
+// MetricsModule serves /metrics to Prometheus
+var MetricsModule = Register(Module{
+	Name:     "metrics",
+	Requires: RequirePrometheus,
+	Mount: func(c *Container) error {
+		handler, ok := c.Server.Metrics.(http.Handler)
+		if !ok {
+			return fmt.Errorf("metrics backend %s can't be scraped", c.Config.MetricsBackend)
+		}
+
+		c.Server.Echo.GET("/metrics", echo.WrapHandler(handler))
+
+		return nil
+	},
+})
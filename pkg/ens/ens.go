@@ -0,0 +1,55 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"strings"
+)
+
+// ErrNotFound is returned when a name, an address or a record isn't set
+var ErrNotFound = errors.New("ens record not found")
+
+// Resolver resolves ENS names and their records
+type Resolver interface {
+	// Resolve returns the address a name points to
+	Resolve(ctx context.Context, name string) (string, error)
+	// ReverseResolve returns the primary name of an address. The name is only returned if it resolves back to
+	// the address
+	ReverseResolve(ctx context.Context, addressHex string) (string, error)
+	// Avatar returns the avatar text record of a name
+	Avatar(ctx context.Context, name string) (string, error)
+}
+
+// IsName returns true if s looks like an ENS name rather than an address
+func IsName(s string) bool {
+	return strings.Contains(s, ".") && !common.IsHexAddress(s)
+}
+
+// Normalize lowercases and trims a name. Full UTS-46 normalization is left to clients
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NameHash implements the ENS namehash algorithm (EIP-137)
+func NameHash(name string) common.Hash {
+	var node common.Hash
+
+	name = Normalize(name)
+	if name == "" {
+		return node
+	}
+
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+
+	return node
+}
+
+// ReverseName returns the name under which the primary name of an address is stored
+func ReverseName(addressHex string) string {
+	return strings.ToLower(common.HexToAddress(addressHex).Hex()[2:]) + ".addr.reverse"
+}
@@ -0,0 +1,79 @@
+package ens
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNameHash(t *testing.T) {
+	t.Parallel()
+
+	// vectors from EIP-137
+	assert.Equal(t, common.Hash{}, NameHash(""))
+	assert.Equal(t, "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae", NameHash("eth").Hex())
+	assert.Equal(t, "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f", NameHash("foo.eth").Hex())
+	assert.Equal(t, NameHash("foo.eth"), NameHash(" FOO.eth"))
+}
+
+func TestIsName(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsName("vitalik.eth"))
+	assert.False(t, IsName(tester.GenerateEthereumAddress(t)))
+	assert.False(t, IsName("vitalik"))
+}
+
+func TestResolverABI(t *testing.T) {
+	t.Parallel()
+
+	_, err := resolver.Pack("text", NameHash("foo.eth"), "avatar")
+	require.NoError(t, err)
+
+	_, err = registry.Pack("resolver", NameHash("foo.eth"))
+	require.NoError(t, err)
+}
+
+func TestFakeResolver(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	resolver := NewFakeResolver()
+	address := tester.GenerateEthereumAddress(t)
+
+	resolver.Register("Foo.eth", address, "ipfs://avatar")
+
+	resolved, err := resolver.Resolve(ctx, "foo.eth")
+	require.NoError(t, err)
+	assert.Equal(t, address, resolved)
+
+	name, err := resolver.ReverseResolve(ctx, address)
+	require.NoError(t, err)
+	assert.Equal(t, "foo.eth", name)
+
+	avatar, err := resolver.Avatar(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, "ipfs://avatar", avatar)
+
+	resolver.Unregister("foo.eth")
+
+	_, err = resolver.Resolve(ctx, "foo.eth")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = resolver.ReverseResolve(ctx, address)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLazyRPCResolver(t *testing.T) {
+	t.Parallel()
+
+	// nothing is dialed until a name is resolved
+	resolver := NewLazyRPCResolver("")
+	defer resolver.Close()
+
+	_, err := resolver.ReverseResolve(context.Background(), tester.GenerateEthereumAddress(t))
+	assert.Error(t, err)
+}
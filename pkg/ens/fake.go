@@ -0,0 +1,89 @@
+package ens
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"sync"
+)
+
+// FakeResolver is an in-memory resolver for tests
+type FakeResolver struct {
+	mu        sync.RWMutex
+	addresses map[string]string
+	names     map[string]string
+	avatars   map[string]string
+}
+
+func NewFakeResolver() *FakeResolver {
+	return &FakeResolver{
+		addresses: map[string]string{},
+		names:     map[string]string{},
+		avatars:   map[string]string{},
+	}
+}
+
+// Register points name to address, sets it as the primary name of address and sets its avatar if not empty
+func (r *FakeResolver) Register(name string, addressHex string, avatar string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name = Normalize(name)
+	addressHex = common.HexToAddress(addressHex).Hex()
+
+	r.addresses[name] = addressHex
+	r.names[addressHex] = name
+
+	if avatar != "" {
+		r.avatars[name] = avatar
+	} else {
+		delete(r.avatars, name)
+	}
+}
+
+// Unregister removes the name and its records
+func (r *FakeResolver) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name = Normalize(name)
+
+	delete(r.names, r.addresses[name])
+	delete(r.addresses, name)
+	delete(r.avatars, name)
+}
+
+func (r *FakeResolver) Resolve(ctx context.Context, name string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	address, ok := r.addresses[Normalize(name)]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return address, nil
+}
+
+func (r *FakeResolver) ReverseResolve(ctx context.Context, addressHex string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[common.HexToAddress(addressHex).Hex()]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return name, nil
+}
+
+func (r *FakeResolver) Avatar(ctx context.Context, name string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	avatar, ok := r.avatars[Normalize(name)]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return avatar, nil
+}
@@ -0,0 +1,77 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// LazyRPCResolver connects to the node on the first resolution, so services that never resolve a name don't need
+// one. A failed connection is tried again by the next resolution
+type LazyRPCResolver struct {
+	url      string
+	mu       sync.Mutex
+	resolver *RPCResolver
+}
+
+func NewLazyRPCResolver(url string) *LazyRPCResolver {
+	return &LazyRPCResolver{url: url}
+}
+
+func (r *LazyRPCResolver) Resolve(ctx context.Context, name string) (string, error) {
+	resolver, err := r.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return resolver.Resolve(ctx, name)
+}
+
+func (r *LazyRPCResolver) ReverseResolve(ctx context.Context, addressHex string) (string, error) {
+	resolver, err := r.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return resolver.ReverseResolve(ctx, addressHex)
+}
+
+func (r *LazyRPCResolver) Avatar(ctx context.Context, name string) (string, error) {
+	resolver, err := r.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return resolver.Avatar(ctx, name)
+}
+
+func (r *LazyRPCResolver) connect(ctx context.Context) (*RPCResolver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.resolver == nil {
+		if r.url == "" {
+			return nil, errors.New("no rpc node configured to resolve ens names")
+		}
+
+		resolver, err := NewRPCResolver(ctx, r.url)
+		if err != nil {
+			return nil, err
+		}
+
+		r.resolver = resolver
+	}
+
+	return r.resolver, nil
+}
+
+// Close closes the connection if one was opened
+func (r *LazyRPCResolver) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.resolver != nil {
+		r.resolver.Close()
+		r.resolver = nil
+	}
+}
@@ -0,0 +1,147 @@
+package ens
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"strings"
+)
+
+// RegistryAddress is the address of the ENS registry, identical on mainnet and the main testnets
+const RegistryAddress = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+const registryABI = `[
+	{"name":"resolver","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+]`
+
+const resolverABI = `[
+	{"name":"addr","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"name","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]},
+	{"name":"text","type":"function","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"},{"name":"key","type":"string"}],"outputs":[{"name":"","type":"string"}]}
+]`
+
+var (
+	registry = mustParseABI(registryABI)
+	resolver = mustParseABI(resolverABI)
+)
+
+func mustParseABI(s string) abi.ABI {
+	a, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(fmt.Errorf("failed to parse abi: %w", err))
+	}
+
+	return a
+}
+
+// RPCResolver resolves names through the ENS contracts with eth_call
+type RPCResolver struct {
+	client   *ethclient.Client
+	registry common.Address
+}
+
+// NewRPCResolver connects to the JSON-RPC node at url
+func NewRPCResolver(ctx context.Context, url string) (*RPCResolver, error) {
+	client, err := chain.Dial(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial rpc node: %w", err)
+	}
+
+	return &RPCResolver{ethclient.NewClient(client), common.HexToAddress(RegistryAddress)}, nil
+}
+
+func (r *RPCResolver) Resolve(ctx context.Context, name string) (string, error) {
+	node := NameHash(name)
+
+	var address common.Address
+	if err := r.callResolver(ctx, node, &address, "addr", node); err != nil {
+		return "", err
+	}
+	if address == (common.Address{}) {
+		return "", ErrNotFound
+	}
+
+	return address.Hex(), nil
+}
+
+func (r *RPCResolver) ReverseResolve(ctx context.Context, addressHex string) (string, error) {
+	node := NameHash(ReverseName(addressHex))
+
+	var name string
+	if err := r.callResolver(ctx, node, &name, "name", node); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", ErrNotFound
+	}
+
+	// anyone can claim any name in their reverse record, it only counts if the name points back to the address
+	resolved, err := r.Resolve(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if resolved != common.HexToAddress(addressHex).Hex() {
+		return "", ErrNotFound
+	}
+
+	return Normalize(name), nil
+}
+
+func (r *RPCResolver) Avatar(ctx context.Context, name string) (string, error) {
+	node := NameHash(name)
+
+	var avatar string
+	if err := r.callResolver(ctx, node, &avatar, "text", node, "avatar"); err != nil {
+		return "", err
+	}
+	if avatar == "" {
+		return "", ErrNotFound
+	}
+
+	return avatar, nil
+}
+
+// callResolver calls method on the resolver of node and unpacks the result into out
+func (r *RPCResolver) callResolver(ctx context.Context, node common.Hash, out interface{}, method string, args ...interface{}) error {
+	var address common.Address
+	if err := r.call(ctx, r.registry, registry, &address, "resolver", node); err != nil {
+		return err
+	}
+	if address == (common.Address{}) {
+		return ErrNotFound
+	}
+
+	return r.call(ctx, address, resolver, out, method, args...)
+}
+
+func (r *RPCResolver) call(ctx context.Context, contract common.Address, a abi.ABI, out interface{}, method string, args ...interface{}) error {
+	data, err := a.Pack(method, args...)
+	if err != nil {
+		return fmt.Errorf("unable to pack %s: %w", method, err)
+	}
+
+	result, err := r.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to call %s on %s: %w", method, contract.Hex(), err)
+	}
+
+	// resolvers that don't implement the method revert or return nothing
+	if len(result) == 0 {
+		return ErrNotFound
+	}
+
+	if err = a.UnpackIntoInterface(out, method, result); err != nil {
+		return fmt.Errorf("unable to unpack %s: %w", method, err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying rpc connection
+func (r *RPCResolver) Close() {
+	r.client.Close()
+}
@@ -1,16 +1,12 @@
 package helpers
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/cristalhq/aconfig"
 	"github.com/cristalhq/aconfig/aconfigyaml"
 	"go.uber.org/zap"
-	"net/url"
-	"path/filepath"
 )
 
 // MustLoadConfig load configuration files into struct c or panics if it fails
@@ -27,39 +23,15 @@ func MustLoadConfig(c interface{}, filenames ...string) {
 	}
 }
 
+// MustLoadSecrets loads the secrets found at a file://, secretsmanager:// or ssm:// url into c, through its env tags
 func MustLoadSecrets(c interface{}, rawurl string) {
-	var err error
-
-	var u *url.URL
-	if u, err = url.Parse(rawurl); err != nil {
-		panic(fmt.Errorf("failed to parse secrets url: %w", err))
-	}
-
-	switch u.Scheme {
-	case "file":
-		MustLoadConfig(c, filepath.Join(u.Host, u.Path))
-	case "secretsmanager":
-		MustLoadSecretsManager(c, u.Host)
-	default:
-		panic(fmt.Errorf("unknown url scheme to retrieve secrets: %s", u.Scheme))
-	}
-}
-
-// MustLoadSecretsManager loads the secrets stored in secrets manager into v
-func MustLoadSecretsManager(v interface{}, id string) {
-	var err error
-
-	svc := secretsmanager.New(session.Must(session.NewSession()))
-
-	var val *secretsmanager.GetSecretValueOutput
-	if val, err = svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
-		SecretId: &id,
-	}); err != nil {
-		panic(fmt.Errorf("failed to retrieve secrets (%s): %w", id, err))
+	values, err := LoadSecrets(rawurl)
+	if err != nil {
+		panic(err)
 	}
 
-	if err = json.Unmarshal([]byte(*val.SecretString), &v); err != nil {
-		panic(fmt.Errorf("failed to marshal secrets (%s): %w", id, err))
+	if err = DecodeValuesInto(c, values); err != nil {
+		panic(fmt.Errorf("failed to decode secrets (%s): %w", rawurl, err))
 	}
 }
 
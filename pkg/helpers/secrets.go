@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/caarlos0/env/v6"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSecrets returns the values found at a file://, secretsmanager:// or ssm:// url, keyed by name. Files and
+// secrets manager secrets hold a flat YAML or JSON object, ssm:// loads every parameter under a path, named after
+// the last segment of the parameter name
+func LoadSecrets(rawurl string) (map[string]string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse secrets url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return LoadValuesFile(filepath.Join(u.Host, u.Path))
+	case "secretsmanager":
+		return loadSecretsManagerValues(u.Host)
+	case "ssm":
+		return loadParameterStoreValues("/" + strings.Trim(path.Join(u.Host, u.Path), "/"))
+	default:
+		return nil, fmt.Errorf("unknown url scheme to retrieve secrets: %s", u.Scheme)
+	}
+}
+
+// LoadValuesFile reads a flat YAML or JSON object from a file
+func LoadValuesFile(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	values, err := DecodeValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+
+	return values, nil
+}
+
+// DecodeValues decodes a flat YAML or JSON object into strings. Lists are joined with commas, the way env
+// variables hold them
+func DecodeValues(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return nil, fmt.Errorf("%s: nested objects aren't supported", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return values, nil
+}
+
+func loadSecretsManagerValues(id string) (map[string]string, error) {
+	svc := secretsmanager.New(session.Must(session.NewSession()))
+
+	val, err := svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: &id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve secrets (%s): %w", id, err)
+	}
+
+	values, err := DecodeValues([]byte(aws.StringValue(val.SecretString)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secrets (%s): %w", id, err)
+	}
+
+	return values, nil
+}
+
+func loadParameterStoreValues(parametersPath string) (map[string]string, error) {
+	svc := ssm.New(session.Must(session.NewSession()))
+
+	values := map[string]string{}
+
+	err := svc.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+		Path:           aws.String(parametersPath),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, parameter := range page.Parameters {
+			values[path.Base(aws.StringValue(parameter.Name))] = aws.StringValue(parameter.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve parameters (%s): %w", parametersPath, err)
+	}
+
+	return values, nil
+}
+
+// DecodeValuesInto fills v, a struct with env tags, from values keyed by env variable name. Values are parsed as
+// env variables are, into ints, bools, durations or comma separated lists
+func DecodeValuesInto(v interface{}, values map[string]string) error {
+	return env.Parse(v, env.Options{Environment: values})
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMustLoadSecrets(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "secrets.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("NAME: api\nPORT: 8080\nDEBUG: true\nTIMEOUT: 5s\nCHAINS: [1, 137]\n"), 0o600))
+
+	var config struct {
+		Name    string        `env:"NAME"`
+		Port    int           `env:"PORT"`
+		Debug   bool          `env:"DEBUG"`
+		Timeout time.Duration `env:"TIMEOUT"`
+		Chains  []int64       `env:"CHAINS"`
+	}
+	MustLoadSecrets(&config, "file://"+file)
+
+	assert.Equal(t, "api", config.Name)
+	assert.Equal(t, 8080, config.Port)
+	assert.True(t, config.Debug)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, []int64{1, 137}, config.Chains)
+}
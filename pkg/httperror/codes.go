@@ -2,24 +2,46 @@ package httperror
 
 import (
 	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/labstack/echo/v4"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/pkg/errors"
 	"net/http"
+	"sort"
 )
 
-// NewError generates function, so we can predefine a list of http errors without knowing the cause at compile time
+// NewError generates function, so we can predefine a list of http errors without knowing the cause at compile time.
+// The error is registered in the domain catalog, so its code can't collide with a domain error
 func NewError(statusCode int, errorCode int, message string) func(error) *Error {
+	def := domain.Register(domain.Definition{
+		Code:     errorCode,
+		Status:   statusCode,
+		Severity: statusSeverity(statusCode),
+		Message:  message,
+	})
+
 	return func(cause error) *Error {
-		e := errors.WithStack(cause)
+		return fromDefinition(def, message, cause)
+	}
+}
 
-		return &Error{
-			StatusCode:    statusCode,
-			ErrorCode:     errorCode,
-			OutputMessage: message,
-			Cause:         e,
-		}
+func fromDefinition(def domain.Definition, message string, cause error) *Error {
+	return &Error{
+		StatusCode:    def.Status,
+		ErrorCode:     def.Code,
+		Severity:      def.Severity,
+		OutputMessage: message,
+		Cause:         errors.WithStack(cause),
+	}
+}
+
+// statusSeverity is the severity of errors that don't declare one
+func statusSeverity(statusCode int) domain.Severity {
+	if statusCode >= http.StatusInternalServerError {
+		return domain.SeverityError
 	}
+
+	return domain.SeverityInfo
 }
 
 // FromEcho is similar to NewError but handles echo errors
@@ -30,23 +52,66 @@ func FromEcho(httpCode int, errorCode int, message string) func(*echo.HTTPError)
 		return &Error{
 			StatusCode:    httpCode,
 			ErrorCode:     errorCode,
+			Severity:      statusSeverity(httpCode),
 			OutputMessage: fmt.Sprintf("%s: %s", message, cause.Message),
 			Cause:         e,
 		}
 	}
 }
 
+// FromValidation reports every invalid field of a failed validation
+func FromValidation(errs validation.Errors) *Error {
+	e := CoreRequestValidationFailed(errs)
+	e.Fields = fieldErrors("", errs)
+
+	return e
+}
+
+// fieldErrors flattens nested validation errors, sorted by field
+func fieldErrors(prefix string, errs validation.Errors) []FieldError {
+	var fields []FieldError
+
+	for field, err := range errs {
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+
+		if nested, ok := err.(validation.Errors); ok {
+			fields = append(fields, fieldErrors(field, nested)...)
+			continue
+		}
+
+		fields = append(fields, FieldError{Field: field, Reason: err.Error()})
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+
+	return fields
+}
+
 func FromDomain(err error) error {
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		return FromValidation(fields)
+	}
+
+	// the outermost domain error decides the response, err is kept whole as the cause for the logs
 	var result *domain.Error
-	if dErr, ok := err.(*domain.Error); ok {
-		result = dErr
-	} else {
+	if !errors.As(err, &result) {
 		result = domain.ErrCodeUnexpected(err)
 	}
 
-	httpError := NewError(ErrStatusCode[result.Code], result.Code, result.Message)
+	def, ok := domain.Lookup(result.Code)
+	if !ok {
+		def = domain.Definition{Code: result.Code, Status: http.StatusInternalServerError, Severity: domain.SeverityError}
+	}
 
-	return httpError(result.Cause)
+	e := fromDefinition(def, result.Message, err)
+	e.Params = result.Params
+
+	return e
 }
 
 var (
@@ -58,33 +123,8 @@ var (
 	CoreUnexpectedDataType            = NewError(http.StatusBadRequest, 5, "unexpected data type")
 	CoreRequestFileFailed             = NewError(http.StatusBadRequest, 6, "failed to get file from request")
 	CoreFileOpenFailed                = NewError(http.StatusInternalServerError, 7, "failed to open file")
-	CoreRequestValidationFailed       = NewError(http.StatusBadRequest, 8, "failed to validate request")
+	CoreRequestValidationFailed       = NewError(http.StatusUnprocessableEntity, 8, "failed to validate request")
 	CoreRequestStringConversionFailed = NewError(http.StatusBadRequest, 9, "failed to convert string")
 	CoreUnauthorized                  = NewError(http.StatusUnauthorized, 10, "unauthorized")
 	CoreUnprocessableEntity           = NewError(http.StatusUnprocessableEntity, 11, "unprocessable entity")
 )
-
-var ErrStatusCode = map[int]int{
-	domain.ErrCodeUnexpected(nil).Code: http.StatusInternalServerError,
-	domain.ErrNotFound(nil).Code:       http.StatusNotFound,
-
-	domain.ErrInvalidEthereumAddressHex(nil).Code: http.StatusUnprocessableEntity,
-	domain.ErrInvalidSignatureSize(nil).Code:      http.StatusUnprocessableEntity,
-	domain.ErrInvalidSignatureHex(nil).Code:       http.StatusUnprocessableEntity,
-	domain.ErrInvalidSignature(nil).Code:          http.StatusUnprocessableEntity,
-
-	domain.ErrUserGetFailed(nil).Code:                    http.StatusInternalServerError,
-	domain.ErrUserStoreFailed(nil).Code:                  http.StatusInternalServerError,
-	domain.ErrUserUpdateFailed(nil).Code:                 http.StatusInternalServerError,
-	domain.ErrUserRemoveFailed(nil).Code:                 http.StatusInternalServerError,
-	domain.ErrUserUpdateDefaultCharacterFailed(nil).Code: http.StatusInternalServerError,
-
-	domain.ErrChallengeGetFailed(nil).Code:    http.StatusInternalServerError,
-	domain.ErrChallengeStoreFailed(nil).Code:  http.StatusInternalServerError,
-	domain.ErrChallengeRemoveFailed(nil).Code: http.StatusInternalServerError,
-
-	domain.ErrCharactersQueryFailed(nil).Code: http.StatusInternalServerError,
-	domain.ErrCharacterClaimFailed(nil).Code:  http.StatusInternalServerError,
-
-	domain.ErrClansQueryFailed(nil).Code: http.StatusInternalServerError,
-}
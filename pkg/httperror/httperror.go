@@ -1,27 +1,136 @@
 package httperror
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/i18n"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 )
 
-type Response struct {
-	ErrorCode int    `json:"error_code"`
-	Error     string `json:"error"`
+const (
+	// MIMEApplicationProblemJSON is the content type of error responses
+	MIMEApplicationProblemJSON = "application/problem+json"
+	// HeaderAcceptLanguage lists the locales the client prefers
+	HeaderAcceptLanguage = "Accept-Language"
+	// HeaderContentLanguage is the locale of the error message
+	HeaderContentLanguage = "Content-Language"
+)
+
+// Problem is an RFC 7807 problem details response
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// ErrorCode identifies the error for clients, the status alone is too coarse
+	ErrorCode int          `json:"error_code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError explains why a request field is invalid
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
 
 type Error struct {
 	StatusCode int
 	ErrorCode  int
-	// message returned to the client
+	// Severity is the level the error is logged at
+	Severity domain.Severity
+	// message returned to the client when it has no translation
 	OutputMessage string
-	Cause         error
+	// Params fill the placeholders of the localized message
+	Params map[string]interface{}
+	// Fields lists the invalid request fields of a validation failure
+	Fields []FieldError
+	Cause  error
+}
+
+// Message returns the message returned to the client in locale
+func (e *Error) Message(locale string) string {
+	if msg, ok := i18n.Message(locale, e.ErrorCode, e.Params); ok {
+		return msg
+	}
+
+	return e.OutputMessage
+}
+
+// Problem returns the response describing e in locale, instance identifies the request that failed
+func (e *Error) Problem(instance string, locale string) Problem {
+	return Problem{
+		Type:      problemType(e.ErrorCode),
+		Title:     http.StatusText(e.StatusCode),
+		Status:    e.StatusCode,
+		Detail:    e.Message(locale),
+		Instance:  instance,
+		ErrorCode: e.ErrorCode,
+		Errors:    e.Fields,
+	}
+}
+
+// problemType links registered errors to their entry in the /errors catalog
+func problemType(code int) string {
+	if _, ok := domain.Lookup(code); !ok {
+		return "about:blank"
+	}
+
+	return fmt.Sprintf("/errors/%d", code)
 }
 
+// SeverityOf returns the level err is logged at
+func SeverityOf(err error) domain.Severity {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Severity
+	}
+
+	var echoErr *echo.HTTPError
+	if errors.As(err, &echoErr) {
+		return statusSeverity(echoErr.Code)
+	}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		if def, ok := domain.Lookup(domainErr.Code); ok {
+			return def.Severity
+		}
+	}
+
+	return domain.SeverityError
+}
+
+// Error returns the code and message followed by the cause. It's meant for logs, clients only get the problem
 func (e Error) Error() string {
-	return fmt.Sprintf("[%d] %s: %+v", e.ErrorCode, e.OutputMessage, e.Cause)
+	if e.Cause == nil {
+		return fmt.Sprintf("[%d] %s", e.ErrorCode, e.OutputMessage)
+	}
+
+	return fmt.Sprintf("[%d] %s: %v", e.ErrorCode, e.OutputMessage, e.Cause)
+}
+
+// Format prints the stack trace of the cause with %+v
+func (e Error) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+') && e.Cause != nil:
+		fmt.Fprintf(s, "[%d] %s: %+v", e.ErrorCode, e.OutputMessage, e.Cause)
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		io.WriteString(s, e.Error())
+	}
+}
+
+func (e Error) Unwrap() error {
+	return e.Cause
 }
 
 func (e Error) Is(other error) bool {
@@ -36,13 +145,26 @@ func (e Error) Is(other error) bool {
 
 func NewErrorHandler(logger *zap.SugaredLogger) echo.HTTPErrorHandler {
 	return func(err error, c echo.Context) {
-		var e *Error
+		logger := logging.FromContext(c.Request().Context(), logger)
+
+		var (
+			e         *Error
+			echoErr   *echo.HTTPError
+			fields    validation.Errors
+			domainErr *domain.Error
+		)
 
-		switch err.(type) {
-		case *echo.HTTPError:
-			e = CoreEchoError(err.(*echo.HTTPError))
-		case *Error:
-			e = err.(*Error)
+		switch {
+		case errors.As(err, &e):
+		case errors.As(err, &echoErr):
+			// keeps the status of routing and binding failures, such as 404 and 405
+			e = CoreEchoError(echoErr)
+			e.StatusCode = echoErr.Code
+			e.Severity = statusSeverity(e.StatusCode)
+		case errors.As(err, &fields):
+			e = FromValidation(fields)
+		case errors.As(err, &domainErr):
+			e = FromDomain(err).(*Error)
 		default:
 			e = CoreUnknownError(err)
 		}
@@ -52,10 +174,13 @@ func NewErrorHandler(logger *zap.SugaredLogger) echo.HTTPErrorHandler {
 			return
 		}
 
+		locale := i18n.Negotiate(preferredLocale(c), c.Request().Header.Get(HeaderAcceptLanguage))
+		c.Response().Header().Set(HeaderContentLanguage, locale)
+
 		if c.Request().Method == http.MethodHead { // Issue https://github.com/labstack/echo/issues/608
 			err = c.NoContent(e.StatusCode)
 		} else {
-			err = c.JSON(e.StatusCode, Response{e.ErrorCode, e.OutputMessage})
+			err = writeProblem(c, e.Problem(c.Response().Header().Get(logging.HeaderRequestID), locale))
 		}
 
 		if err != nil {
@@ -63,3 +188,18 @@ func NewErrorHandler(logger *zap.SugaredLogger) echo.HTTPErrorHandler {
 		}
 	}
 }
+
+// preferredLocale returns the locale the authenticated user prefers, if any
+func preferredLocale(c echo.Context) string {
+	locale, _ := c.Get(i18n.ContextKey).(string)
+	return locale
+}
+
+func writeProblem(c echo.Context, problem Problem) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(problem.Status, MIMEApplicationProblemJSON, body)
+}
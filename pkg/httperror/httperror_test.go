@@ -1,8 +1,18 @@
 package httperror
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/i18n"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -10,3 +20,158 @@ func TestIsErrors(t *testing.T) {
 	err1 := CoreUnknownError(errors.New("test my error"))
 	assert.True(t, errors.Is(err1, CoreUnknownError(nil)))
 }
+
+func newTestContext() (echo.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), rec
+}
+
+func TestErrorHandler_Problem(t *testing.T) {
+	t.Parallel()
+
+	handler := NewErrorHandler(zap.NewNop().Sugar())
+
+	input := domain.NewUserUpdateInput("usr_1", 0).WithUsername("a")
+	c, rec := newTestContext()
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+
+	handler(FromDomain(input.Validate()), c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+
+	assert.Equal(t, "/errors/8", problem.Type)
+	assert.Equal(t, http.StatusText(http.StatusUnprocessableEntity), problem.Title)
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "req-1", problem.Instance)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "username", problem.Errors[0].Field)
+	assert.NotEmpty(t, problem.Errors[0].Reason)
+}
+
+func TestErrorHandler_EchoStatus(t *testing.T) {
+	t.Parallel()
+
+	handler := NewErrorHandler(zap.NewNop().Sugar())
+
+	c, rec := newTestContext()
+	handler(echo.ErrNotFound, c)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+}
+
+func TestFromValidation_Nested(t *testing.T) {
+	t.Parallel()
+
+	e := FromValidation(validation.Errors{
+		"wallets": validation.Errors{"0": errors.New("must be a valid address")},
+		"name":    errors.New("cannot be blank"),
+	})
+
+	assert.Equal(t, []FieldError{
+		{Field: "name", Reason: "cannot be blank"},
+		{Field: "wallets.0", Reason: "must be a valid address"},
+	}, e.Fields)
+}
+
+func TestFromDomain_Registry(t *testing.T) {
+	t.Parallel()
+
+	e := FromDomain(domain.ErrPreconditionFailed(errors.New("stale"))).(*Error)
+
+	assert.Equal(t, http.StatusPreconditionFailed, e.StatusCode)
+	assert.Equal(t, domain.SeverityInfo, e.Severity)
+	assert.Equal(t, "/errors/1005", e.Problem("", i18n.DefaultLocale).Type)
+
+	// errors outside the domain are unexpected
+	e = FromDomain(errors.New("boom")).(*Error)
+
+	assert.Equal(t, http.StatusInternalServerError, e.StatusCode)
+	assert.Equal(t, domain.SeverityError, e.Severity)
+	assert.Equal(t, domain.SeverityError, SeverityOf(e))
+	assert.Equal(t, domain.SeverityInfo, SeverityOf(echo.ErrNotFound))
+}
+
+func TestFromDomain_Wrapped(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("pq: relation users does not exist")
+	err := FromDomain(fmt.Errorf("find: %w", domain.ErrNotFound(cause)))
+
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusNotFound, e.StatusCode)
+	assert.ErrorIs(t, err, domain.ErrNotFound(nil))
+	assert.ErrorIs(t, err, cause)
+}
+
+func TestErrorHandler_HidesCause(t *testing.T) {
+	t.Parallel()
+
+	handler := NewErrorHandler(zap.NewNop().Sugar())
+	cause := errors.New("pq: relation users does not exist")
+
+	// domain errors that weren't converted by the controller still get their status
+	c, rec := newTestContext()
+	handler(fmt.Errorf("get: %w", domain.ErrUserGetFailed(cause)), c)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "relation users")
+	assert.NotContains(t, rec.Body.String(), ".go:")
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, domain.ErrUserGetFailed(nil).Code, problem.ErrorCode)
+	assert.Equal(t, "failed to get user", problem.Detail)
+}
+
+func TestCatalogComplete(t *testing.T) {
+	t.Parallel()
+
+	// every error registered by domain and httperror has a message in each locale
+	var codes []int
+	for _, def := range domain.Definitions() {
+		codes = append(codes, def.Code)
+	}
+
+	assert.Empty(t, i18n.Missing(codes))
+}
+
+func TestErrorHandler_Locale(t *testing.T) {
+	t.Parallel()
+
+	handler := NewErrorHandler(zap.NewNop().Sugar())
+
+	detail := func(c echo.Context, rec *httptest.ResponseRecorder) string {
+		handler(FromDomain(domain.ErrInvalidSignatureSize(nil).WithParam("size", 65)), c)
+
+		var problem Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+
+		return problem.Detail
+	}
+
+	c, rec := newTestContext()
+	c.Request().Header.Set(HeaderAcceptLanguage, "ja-JP,ja;q=0.9,en;q=0.8")
+	assert.Equal(t, "署名は65バイトである必要があります", detail(c, rec))
+	assert.Equal(t, "ja", rec.Header().Get(HeaderContentLanguage))
+
+	// the preference of the user wins over the header
+	c, rec = newTestContext()
+	c.Request().Header.Set(HeaderAcceptLanguage, "ja")
+	c.Set(i18n.ContextKey, "fr")
+	assert.Equal(t, "la signature doit faire 65 octets", detail(c, rec))
+
+	// unsupported locales fall back to English
+	c, rec = newTestContext()
+	c.Request().Header.Set(HeaderAcceptLanguage, "de-DE")
+	assert.Equal(t, "signature must be 65 bytes", detail(c, rec))
+	assert.Equal(t, i18n.DefaultLocale, rec.Header().Get(HeaderContentLanguage))
+}
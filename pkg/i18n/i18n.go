@@ -0,0 +1,125 @@
+// Package i18n translates the messages returned to clients
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ContextKey is the key of the locale preferred by the authenticated user in the echo context
+const ContextKey = "locale"
+
+// DefaultLocale is the locale of clients that don't prefer a supported one, every message has an English version
+const DefaultLocale = "en"
+
+// Locales are the supported locales, DefaultLocale first
+var Locales = []string{DefaultLocale, "ja", "fr"}
+
+//go:embed locales/*.yaml
+var files embed.FS
+
+// catalog holds the messages of each locale keyed by error code
+var catalog = mustLoadCatalog()
+
+var matcher = newMatcher()
+
+func mustLoadCatalog() map[string]map[int]string {
+	catalog := map[string]map[int]string{}
+
+	for _, locale := range Locales {
+		data, err := files.ReadFile(path.Join("locales", locale+".yaml"))
+		if err != nil {
+			panic(fmt.Errorf("missing messages of locale %s: %w", locale, err))
+		}
+
+		messages := map[int]string{}
+		if err = yaml.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Errorf("invalid messages of locale %s: %w", locale, err))
+		}
+
+		catalog[locale] = messages
+	}
+
+	return catalog
+}
+
+func newMatcher() language.Matcher {
+	tags := make([]language.Tag, len(Locales))
+	for i, locale := range Locales {
+		tags[i] = language.MustParse(locale)
+	}
+
+	return language.NewMatcher(tags)
+}
+
+// Supported reports whether messages are translated to locale
+func Supported(locale string) bool {
+	_, ok := catalog[locale]
+	return ok
+}
+
+// Negotiate returns the locale to answer in: the preference of the user when it's supported, otherwise the best
+// match of an Accept-Language header, otherwise DefaultLocale
+func Negotiate(preference string, acceptLanguage string) string {
+	if Supported(preference) {
+		return preference
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+
+	return Locales[index]
+}
+
+// Message returns the message of an error code in locale, or in English when it isn't translated. {name} is
+// replaced by the matching parameter. It reports false when no locale has a message for the code
+func Message(locale string, code int, params map[string]interface{}) (string, bool) {
+	msg, ok := catalog[locale][code]
+	if !ok {
+		msg, ok = catalog[DefaultLocale][code]
+	}
+
+	if !ok {
+		return "", false
+	}
+
+	if len(params) == 0 {
+		return msg, true
+	}
+
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(msg), true
+}
+
+// Missing returns the codes of each locale that have no message, sorted. It's empty when every code is translated
+func Missing(codes []int) map[string][]int {
+	missing := map[string][]int{}
+
+	for _, locale := range Locales {
+		for _, code := range codes {
+			if _, ok := catalog[locale][code]; !ok {
+				missing[locale] = append(missing[locale], code)
+			}
+		}
+
+		sort.Ints(missing[locale])
+	}
+
+	return missing
+}
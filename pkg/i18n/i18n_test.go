@@ -0,0 +1,45 @@
+package i18n
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "ja", Negotiate("", "ja-JP,en;q=0.5"))
+	assert.Equal(t, "fr", Negotiate("", "de;q=0.9,fr-CA;q=0.8"))
+	assert.Equal(t, "fr", Negotiate("fr", "ja"))
+	assert.Equal(t, "ja", Negotiate("de", "ja"))
+	assert.Equal(t, DefaultLocale, Negotiate("", "de"))
+	assert.Equal(t, DefaultLocale, Negotiate("", ""))
+	assert.Equal(t, DefaultLocale, Negotiate("", "not a header;;"))
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	msg, ok := Message("fr", 1001, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "Introuvable", msg)
+
+	msg, ok = Message("ja", 2002, map[string]interface{}{"size": 65})
+	assert.True(t, ok)
+	assert.Equal(t, "署名は65バイトである必要があります", msg)
+
+	// unsupported locales get English
+	msg, ok = Message("de", 1001, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "Not found", msg)
+
+	_, ok = Message("en", 999999, nil)
+	assert.False(t, ok)
+}
+
+func TestMissing(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, Missing([]int{1001, 2002}))
+	assert.Equal(t, map[string][]int{"en": {999999}, "ja": {999999}, "fr": {999999}}, Missing([]int{999999}))
+}
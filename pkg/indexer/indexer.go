@@ -0,0 +1,79 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"math/big"
+)
+
+// TransferTopic is the keccak256 hash of the ERC-721 (and ERC-20) Transfer event signature
+var TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// erc721TransferTopics is the number of topics of an ERC-721 Transfer log. ERC-20 transfers share the same
+// signature but only index from and to, so they have one topic less
+const erc721TransferTopics = 4
+
+// Source provides blocks and Transfer logs to the indexer
+type Source interface {
+	// LatestBlock returns the number of the most recent block
+	LatestBlock(ctx context.Context) (uint64, error)
+	// BlockHash returns the hash of the block at height n
+	BlockHash(ctx context.Context, n uint64) (common.Hash, error)
+	// Logs returns the Transfer logs emitted by contracts between blocks from and to inclusively
+	Logs(ctx context.Context, contracts []common.Address, from, to uint64) ([]types.Log, error)
+}
+
+// DecodeTransfer converts an ERC-721 Transfer log emitted on chainID into a transfer
+func DecodeTransfer(chainID domain.ChainID, log types.Log) (domain.Transfer, error) {
+	if len(log.Topics) != erc721TransferTopics || log.Topics[0] != TransferTopic {
+		return domain.Transfer{}, fmt.Errorf("log %s:%d is not an ERC-721 transfer", log.TxHash.Hex(), log.Index)
+	}
+
+	return domain.Transfer{
+		ChainID:         chainID,
+		ContractAddress: domain.EthereumAddress(log.Address),
+		TokenID:         new(big.Int).SetBytes(log.Topics[3].Bytes()).String(),
+		FromAddress:     domain.EthereumAddress(common.BytesToAddress(log.Topics[1].Bytes())),
+		ToAddress:       domain.EthereumAddress(common.BytesToAddress(log.Topics[2].Bytes())),
+		BlockNumber:     log.BlockNumber,
+		BlockHash:       log.BlockHash.Hex(),
+		LogIndex:        log.Index,
+		TransactionHash: log.TxHash.Hex(),
+	}, nil
+}
+
+// DecodeTransfers converts logs into transfers, skipping logs that aren't ERC-721 transfers
+func DecodeTransfers(chainID domain.ChainID, logs []types.Log) []domain.Transfer {
+	var transfers []domain.Transfer
+
+	for _, log := range logs {
+		// removed logs are only sent by subscriptions, reorgs are detected with block hashes instead
+		if log.Removed {
+			continue
+		}
+
+		transfer, err := DecodeTransfer(chainID, log)
+		if err != nil {
+			continue
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers
+}
+
+// Addresses converts ethereum addresses into the addresses of the rpc client
+func Addresses(ethereumAddresses []domain.EthereumAddress) []common.Address {
+	addresses := make([]common.Address, 0, len(ethereumAddresses))
+
+	for _, address := range ethereumAddresses {
+		addresses = append(addresses, common.Address(address))
+	}
+
+	return addresses
+}
@@ -0,0 +1,83 @@
+package indexer
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var (
+	testContract = domain.EthereumAddress(common.HexToAddress("0x5AEDA56215b167893e80B4fE645BA6d5Bab767DE"))
+	testAlice    = domain.EthereumAddress(common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	testBob      = domain.EthereumAddress(common.HexToAddress("0x2222222222222222222222222222222222222222"))
+)
+
+func TestDecodeTransfers(t *testing.T) {
+	t.Parallel()
+
+	source, err := NewFileSource("testdata/transfers.json")
+	require.NoError(t, err)
+
+	logs, err := source.Logs(context.Background(), nil, 0, 5)
+	require.NoError(t, err)
+	require.Len(t, logs, 4)
+
+	transfers := DecodeTransfers(domain.ChainIDMainnet, logs)
+
+	// the ERC-20 transfer of block 3 is skipped
+	require.Len(t, transfers, 3)
+
+	assert.Equal(t, domain.ChainIDMainnet, transfers[0].ChainID)
+	assert.Equal(t, testContract, transfers[0].ContractAddress)
+	assert.Equal(t, "1", transfers[0].TokenID)
+	assert.True(t, transfers[0].FromAddress.IsZero())
+	assert.Equal(t, testAlice, transfers[0].ToAddress)
+	assert.Equal(t, uint64(1), transfers[0].BlockNumber)
+
+	assert.Equal(t, "1", transfers[2].TokenID)
+	assert.Equal(t, testAlice, transfers[2].FromAddress)
+	assert.Equal(t, testBob, transfers[2].ToAddress)
+	assert.Equal(t, uint64(4), transfers[2].BlockNumber)
+	assert.Equal(t, uint(3), transfers[2].LogIndex)
+
+	_, err = DecodeTransfer(domain.ChainIDMainnet, logs[3])
+	assert.Error(t, err)
+}
+
+func TestFileSource(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	source, err := NewFileSource("testdata/transfers.json")
+	require.NoError(t, err)
+
+	latest, err := source.LatestBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), latest)
+
+	logs, err := source.Logs(ctx, Addresses([]domain.EthereumAddress{testContract}), 2, 4)
+	require.NoError(t, err)
+	assert.Len(t, logs, 3)
+
+	// other contracts are filtered out
+	logs, err = source.Logs(ctx, Addresses([]domain.EthereumAddress{testAlice}), 0, 5)
+	require.NoError(t, err)
+	assert.Len(t, logs, 0)
+
+	hash, err := source.BlockHash(ctx, 4)
+	require.NoError(t, err)
+
+	// loading another file replaces the chain
+	require.NoError(t, source.Load("testdata/transfers_reorg.json"))
+
+	reorgHash, err := source.BlockHash(ctx, 4)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, reorgHash)
+
+	_, err = source.BlockHash(ctx, 6)
+	assert.Error(t, err)
+}
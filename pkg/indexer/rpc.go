@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"math/big"
+)
+
+// RPCSource polls a JSON-RPC node with eth_blockNumber, eth_getBlockByNumber and eth_getLogs
+type RPCSource struct {
+	client *ethclient.Client
+}
+
+// NewRPCSource connects to the JSON-RPC node at url
+func NewRPCSource(ctx context.Context, url string) (*RPCSource, error) {
+	client, err := chain.Dial(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial rpc node: %w", err)
+	}
+
+	return &RPCSource{ethclient.NewClient(client)}, nil
+}
+
+func (s *RPCSource) LatestBlock(ctx context.Context) (uint64, error) {
+	return s.client.BlockNumber(ctx)
+}
+
+func (s *RPCSource) BlockHash(ctx context.Context, n uint64) (common.Hash, error) {
+	header, err := s.client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return header.Hash(), nil
+}
+
+func (s *RPCSource) Logs(ctx context.Context, contracts []common.Address, from, to uint64) ([]types.Log, error) {
+	return s.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: contracts,
+		Topics:    [][]common.Hash{{TransferTopic}},
+	})
+}
+
+// Close closes the underlying rpc connection
+func (s *RPCSource) Close() {
+	s.client.Close()
+}
@@ -0,0 +1,77 @@
+// Package logging carries the request ID and the logger of a request through its context
+package logging
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"regexp"
+)
+
+// HeaderRequestID identifies a request in responses and in calls to other services
+const HeaderRequestID = "X-Request-Id"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// requestIDPattern bounds IDs sent by clients, they end up in logs and headers
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ValidRequestID reports whether an ID sent by a client can be reused
+func ValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID of ctx, empty outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithLogger returns a copy of ctx carrying the logger of the request
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger of the request, or fallback outside a request
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey).(*zap.SugaredLogger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+// Transport forwards the request ID of the request context to downstream services
+type Transport struct {
+	// Base is the transport making the calls, http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := RequestID(req.Context())
+	if id == "" || req.Header.Get(HeaderRequestID) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// round trippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(HeaderRequestID, id)
+
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, ValidRequestID("f47ac10b-58cc-4372-a567-0e02b2c3d479"))
+	assert.True(t, ValidRequestID("1-5f84c7a9:abc_def.1"))
+
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("id\nforged log line"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", 129)))
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	fallback := zap.NewNop().Sugar()
+	logger := zap.NewNop().Sugar().With("request_id", "req-1")
+
+	assert.Same(t, fallback, FromContext(context.Background(), fallback))
+	assert.Same(t, logger, FromContext(WithLogger(context.Background(), logger), fallback))
+
+	assert.Equal(t, "", RequestID(context.Background()))
+	assert.Equal(t, "req-1", RequestID(WithRequestID(context.Background(), "req-1")))
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(HeaderRequestID))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	call := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		// the request of the caller is left untouched
+		assert.Empty(t, req.Header.Get(HeaderRequestID))
+	}
+
+	call(WithRequestID(context.Background(), "req-1"))
+	call(context.Background())
+
+	assert.Equal(t, []string{"req-1", ""}, received)
+}
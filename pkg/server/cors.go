@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CORSConfig tells browsers which origins may call the API and how
+type CORSConfig struct {
+	// AllowOrigins are origins such as https://app.example.com. https://*.example.com matches every subdomain of
+	// example.com but not example.com itself, * matches any origin. None means no browser may call the API
+	AllowOrigins []string
+	AllowMethods []string
+	AllowHeaders []string
+	// ExposeHeaders are the response headers scripts may read
+	ExposeHeaders []string
+	// AllowCredentials lets browsers send cookies along, it can't be combined with *
+	AllowCredentials bool
+	// MaxAge is how long browsers cache preflight responses, in seconds
+	MaxAge int
+}
+
+// DefaultCORSConfig allows the methods and headers the API uses, from no origin
+var DefaultCORSConfig = CORSConfig{
+	AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
+	AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "If-Match", logging.HeaderRequestID},
+	// browsers hide response headers from scripts unless they're exposed
+	ExposeHeaders: []string{"ETag", logging.HeaderRequestID},
+	MaxAge:        600,
+}
+
+// AnyOrigin allows every origin
+const AnyOrigin = "*"
+
+// Methods are the methods CORS may allow
+var Methods = []interface{}{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// ValidateOrigin checks origin is *, or a scheme and a host with an optional leading *. label
+func ValidateOrigin(origin string) error {
+	if origin == AnyOrigin {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+
+	host := strings.TrimPrefix(u.Host, "*.")
+	if (u.Scheme != "http" && u.Scheme != "https") || host == "" || u.User != nil || u.Path != "" ||
+		u.RawQuery != "" || u.Fragment != "" || strings.Contains(host, "*") {
+		return fmt.Errorf("origin %q must be a scheme and a host, such as https://app.example.com or https://*.example.com", origin)
+	}
+
+	return nil
+}
+
+// CORSMiddleware answers preflight requests and adds the CORS headers to responses of allowed origins
+func CORSMiddleware(config CORSConfig) echo.MiddlewareFunc {
+	allowed := newOriginMatcher(config.AllowOrigins)
+
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return allowed(origin), nil
+		},
+		AllowMethods:     config.AllowMethods,
+		AllowHeaders:     config.AllowHeaders,
+		ExposeHeaders:    config.ExposeHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           config.MaxAge,
+	})
+}
+
+// subdomain is what * stands for in an origin, one or more labels
+var subdomain = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// wildcardOrigin matches the subdomains of suffix
+type wildcardOrigin struct {
+	scheme string
+	suffix string
+}
+
+func (w wildcardOrigin) match(origin string) bool {
+	if !strings.HasPrefix(origin, w.scheme) || !strings.HasSuffix(origin, w.suffix) || len(origin) <= len(w.scheme)+len(w.suffix) {
+		return false
+	}
+
+	return subdomain.MatchString(origin[len(w.scheme) : len(origin)-len(w.suffix)])
+}
+
+// newOriginMatcher returns whether an origin matches one of patterns. Origins are case-insensitive
+func newOriginMatcher(patterns []string) func(origin string) bool {
+	exact := map[string]bool{}
+	var wildcards []wildcardOrigin
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		// https://*.example.com matches the origins starting with https:// and ending with .example.com
+		if scheme, host, ok := strings.Cut(pattern, "://*."); ok {
+			wildcards = append(wildcards, wildcardOrigin{scheme + "://", "." + host})
+			continue
+		}
+
+		exact[pattern] = true
+	}
+
+	return func(origin string) bool {
+		origin = strings.ToLower(origin)
+		if exact[AnyOrigin] || exact[origin] {
+			return true
+		}
+
+		for _, wildcard := range wildcards {
+			if wildcard.match(origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
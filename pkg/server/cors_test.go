@@ -0,0 +1,164 @@
+package server
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func preflight(e *echo.Echo, path string, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set(echo.HeaderOrigin, origin)
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPut)
+	req.Header.Set(echo.HeaderAccessControlRequestHeaders, "authorization,content-type")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultCORSConfig
+	config.AllowOrigins = []string{"https://app.example.com", "https://*.preview.example.com", "http://localhost:3000"}
+	config.AllowCredentials = true
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, config)
+	e.PUT("/users/me", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	for _, origin := range []string{
+		"https://app.example.com",
+		"https://APP.example.com",
+		"https://pr-12.preview.example.com",
+		"https://a.b.preview.example.com",
+		"http://localhost:3000",
+	} {
+		rec := preflight(e, "/users/me", origin)
+		assert.Equal(t, http.StatusNoContent, rec.Code, origin)
+		assert.Equal(t, origin, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+		assert.Equal(t, "GET,PUT,POST,DELETE", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+		assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlAllowHeaders), echo.HeaderAuthorization)
+		assert.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+		assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+	}
+
+	for _, origin := range []string{
+		"https://evil.com",
+		"http://app.example.com",
+		// the wildcard only matches subdomains
+		"https://preview.example.com",
+		"https://.preview.example.com",
+		"https://evil.com/.preview.example.com",
+		"https://app.example.com.evil.com",
+		"http://localhost:3001",
+	} {
+		rec := preflight(e, "/users/me", origin)
+		assert.Equal(t, http.StatusNoContent, rec.Code, origin)
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowMethods), origin)
+	}
+}
+
+func TestCORSMiddleware_SimpleRequest(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultCORSConfig
+	config.AllowOrigins = []string{"https://app.example.com"}
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, config)
+	e.GET("/errors", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/errors", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "ETag,"+logging.HeaderRequestID, rec.Header().Get(echo.HeaderAccessControlExposeHeaders))
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+}
+
+func TestCORSMiddleware_NoOrigins(t *testing.T) {
+	t.Parallel()
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, DefaultCORSConfig)
+	e.PUT("/users/me", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// echo allows every origin when given none, the API allows none
+	rec := preflight(e, "/users/me", "https://app.example.com")
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultCORSConfig
+	config.AllowOrigins = []string{AnyOrigin}
+	config.AllowMethods = []string{http.MethodGet}
+	config.AllowHeaders = []string{"X-Custom"}
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, config)
+	e.PUT("/users/me", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	rec := preflight(e, "/users/me", "https://anywhere.io")
+	assert.Equal(t, "https://anywhere.io", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, http.MethodGet, rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "X-Custom", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+}
+
+func TestValidateOrigin(t *testing.T) {
+	t.Parallel()
+
+	for _, origin := range []string{"*", "https://app.example.com", "https://*.example.com", "http://localhost:3000", "http://*.localhost:3000"} {
+		assert.NoError(t, ValidateOrigin(origin), origin)
+	}
+
+	for _, origin := range []string{
+		"",
+		"app.example.com",
+		"ftp://app.example.com",
+		"https://app.example.com/",
+		"https://app.example.com/path",
+		"https://app.example.com?query",
+		"https://user@app.example.com",
+		"https://*",
+		"https://app.*.example.com",
+		"https://*.*.example.com",
+	} {
+		assert.Error(t, ValidateOrigin(origin), origin)
+	}
+}
+
+func TestStageRewrite(t *testing.T) {
+	t.Parallel()
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, DefaultCORSConfig, "prod", "staging")
+	e.GET("/users/me", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	for path, code := range map[string]int{
+		"/users/me":         http.StatusNoContent,
+		"/prod/users/me":    http.StatusNoContent,
+		"/staging/users/me": http.StatusNoContent,
+		"/dev/users/me":     http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, code, rec.Code, path)
+	}
+}
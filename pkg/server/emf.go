@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emfMaxValues is the number of samples CloudWatch accepts for a metric in a single line
+const emfMaxValues = 100
+
+// emfEmptyValue replaces empty label values, CloudWatch rejects empty dimensions
+const emfEmptyValue = "none"
+
+// EMFMetrics aggregates measures in memory and writes them as CloudWatch embedded metric format lines on Flush.
+// CloudWatch extracts the metrics from the logs of the lambda, nothing is sent from the instance
+type EMFMetrics struct {
+	namespace string
+	writer    io.Writer
+	now       func() time.Time
+
+	mu         sync.Mutex
+	series     map[string]*emfSeries
+	collectors []func(Metrics)
+}
+
+// emfSeries holds the measures sharing the same labels, they're written on the same line
+type emfSeries struct {
+	labels  map[string]string
+	units   map[string]Unit
+	values  map[string]float64
+	samples map[string][]float64
+}
+
+func NewEMFMetrics(namespace string, w io.Writer) *EMFMetrics {
+	return &EMFMetrics{namespace: namespace, writer: w, now: time.Now, series: map[string]*emfSeries{}}
+}
+
+func (m *EMFMetrics) Add(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.get(metric, labels).values[metric.Name] += value
+}
+
+func (m *EMFMetrics) Observe(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.get(metric, labels)
+	s.samples[metric.Name] = append(s.samples[metric.Name], value)
+}
+
+func (m *EMFMetrics) Set(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.get(metric, labels).values[metric.Name] = value
+}
+
+func (m *EMFMetrics) Collect(fn func(Metrics)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectors = append(m.collectors, fn)
+}
+
+// get returns the series of the labels of a measure, m.mu must be held
+func (m *EMFMetrics) get(metric Metric, values []string) *emfSeries {
+	labels := labelValues(metric, values)
+	for name, value := range labels {
+		if value == "" {
+			labels[name] = emfEmptyValue
+		}
+	}
+
+	key := seriesKey(labels)
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &emfSeries{
+			labels:  labels,
+			units:   map[string]Unit{},
+			values:  map[string]float64{},
+			samples: map[string][]float64{},
+		}
+		m.series[key] = s
+	}
+
+	s.units[metric.Name] = metric.Unit
+
+	return s
+}
+
+func seriesKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Flush samples the collected gauges, writes a line per series and starts over
+func (m *EMFMetrics) Flush() error {
+	m.mu.Lock()
+	collectors := append([]func(Metrics){}, m.collectors...)
+	m.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect(m)
+	}
+
+	m.mu.Lock()
+	series := m.series
+	m.series = map[string]*emfSeries{}
+	m.mu.Unlock()
+
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	timestamp := m.now().UnixMilli()
+
+	for _, key := range keys {
+		for _, line := range series[key].lines(m.namespace, timestamp) {
+			data, err := json.Marshal(line)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := m.writer.Write(buf.Bytes())
+	return err
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string            `json:"Namespace"`
+	Dimensions [][]string        `json:"Dimensions"`
+	Metrics    []emfMetricFormat `json:"Metrics"`
+}
+
+type emfMetricFormat struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+// lines returns the log lines of the series. Samples beyond what a line accepts go to the following lines
+func (s *emfSeries) lines(namespace string, timestamp int64) []map[string]interface{} {
+	dimensions := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		dimensions = append(dimensions, name)
+	}
+	sort.Strings(dimensions)
+
+	var lines []map[string]interface{}
+
+	for offset := 0; ; offset += emfMaxValues {
+		line := map[string]interface{}{}
+		var formats []emfMetricFormat
+
+		if offset == 0 {
+			for name, value := range s.values {
+				line[name] = value
+				formats = append(formats, emfMetricFormat{name, s.units[name]})
+			}
+		}
+
+		for name, samples := range s.samples {
+			if offset >= len(samples) {
+				continue
+			}
+
+			end := offset + emfMaxValues
+			if end > len(samples) {
+				end = len(samples)
+			}
+
+			line[name] = samples[offset:end]
+			formats = append(formats, emfMetricFormat{name, s.units[name]})
+		}
+
+		if len(formats) == 0 {
+			return lines
+		}
+
+		sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+
+		for name, value := range s.labels {
+			line[name] = value
+		}
+
+		line["_aws"] = emfMetadata{
+			Timestamp: timestamp,
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  namespace,
+				Dimensions: [][]string{dimensions},
+				Metrics:    formats,
+			}},
+		}
+
+		lines = append(lines, line)
+	}
+}
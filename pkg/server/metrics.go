@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/labstack/echo/v4"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Unit is the CloudWatch unit of a metric, Prometheus carries it in the metric name instead
+type Unit string
+
+const (
+	UnitCount   Unit = "Count"
+	UnitSeconds Unit = "Seconds"
+)
+
+// Metric describes a series. Labels name the values given with each measure, they must take few distinct values
+type Metric struct {
+	Name   string
+	Help   string
+	Unit   Unit
+	Labels []string
+}
+
+var (
+	MetricRequests = Metric{
+		Name:   "http_requests_total",
+		Help:   "Requests served, by route and status",
+		Unit:   UnitCount,
+		Labels: []string{"route", "method", "status"},
+	}
+	MetricRequestDuration = Metric{
+		Name:   "http_request_duration_seconds",
+		Help:   "Time spent serving requests",
+		Unit:   UnitSeconds,
+		Labels: []string{"route", "method"},
+	}
+	MetricAuthAttempts = Metric{
+		Name:   "auth_attempts_total",
+		Help:   "Authorizations, by result and error code of the failures",
+		Unit:   UnitCount,
+		Labels: []string{"method", "result", "reason"},
+	}
+	MetricChallenges = Metric{
+		Name:   "auth_challenges_total",
+		Help:   "Challenges issued",
+		Unit:   UnitCount,
+		Labels: []string{"chain_id"},
+	}
+	MetricDBQueryDuration = Metric{
+		Name:   "db_query_duration_seconds",
+		Help:   "Time spent running statements",
+		Unit:   UnitSeconds,
+		Labels: []string{"statement"},
+	}
+	MetricDBQueryErrors = Metric{
+		Name:   "db_query_errors_total",
+		Help:   "Statements that failed",
+		Unit:   UnitCount,
+		Labels: []string{"statement"},
+	}
+	MetricDBConnections = Metric{
+		Name:   "db_connections",
+		Help:   "Connections of the pool, by state",
+		Unit:   UnitCount,
+		Labels: []string{"state"},
+	}
+	MetricDBConnectionWaits = Metric{
+		Name: "db_connection_waits",
+		Help: "Times a connection was waited for since the pool opened",
+		Unit: UnitCount,
+	}
+	MetricDBConnectionWaitDuration = Metric{
+		Name: "db_connection_wait_seconds",
+		Help: "Time spent waiting for a connection since the pool opened",
+		Unit: UnitSeconds,
+	}
+)
+
+// Metrics records measures. Label values are given in the order of Metric.Labels
+type Metrics interface {
+	// Add increases a counter
+	Add(metric Metric, value float64, labels ...string)
+	// Observe records a sample of a histogram, such as a duration
+	Observe(metric Metric, value float64, labels ...string)
+	// Set sets a gauge
+	Set(metric Metric, value float64, labels ...string)
+	// Collect registers fn to be called before the metrics are emitted, to sample gauges such as pool stats
+	Collect(fn func(Metrics))
+	// Flush emits the metrics recorded so far, when the backend pushes them
+	Flush() error
+}
+
+const (
+	// MetricsBackendNone records nothing
+	MetricsBackendNone = "none"
+	// MetricsBackendEMF writes CloudWatch embedded metric format lines, for lambdas
+	MetricsBackendEMF = "emf"
+	// MetricsBackendPrometheus serves the metrics to Prometheus, for the standalone server
+	MetricsBackendPrometheus = "prometheus"
+)
+
+// MetricsBackends are the supported backends
+var MetricsBackends = []interface{}{MetricsBackendNone, MetricsBackendEMF, MetricsBackendPrometheus}
+
+// NewMetrics creates the metrics of backend. EMF lines are written to w under namespace
+func NewMetrics(backend string, namespace string, w io.Writer) (Metrics, error) {
+	switch backend {
+	case "", MetricsBackendNone:
+		return NopMetrics, nil
+	case MetricsBackendEMF:
+		return NewEMFMetrics(namespace, w), nil
+	case MetricsBackendPrometheus:
+		return NewPrometheusMetrics(), nil
+	default:
+		return nil, fmt.Errorf("unknown metrics backend %q", backend)
+	}
+}
+
+// NopMetrics discards every measure
+var NopMetrics Metrics = nopMetrics{}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Add(Metric, float64, ...string)     {}
+func (nopMetrics) Observe(Metric, float64, ...string) {}
+func (nopMetrics) Set(Metric, float64, ...string)     {}
+func (nopMetrics) Collect(func(Metrics))              {}
+func (nopMetrics) Flush() error                       { return nil }
+
+// MetricsMiddleware counts requests by route and status and measures their duration. Must be registered before
+// LoggerMiddleware so the status of failed requests is known
+func MetricsMiddleware(metrics Metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			// paths of unknown routes would make a series each, the router leaves them as the route
+			route := c.Path()
+			if route == "" || isNotFound(c.Handler()) {
+				route = "unmatched"
+			}
+
+			method := c.Request().Method
+			metrics.Add(MetricRequests, 1, route, method, strconv.Itoa(c.Response().Status))
+			metrics.Observe(MetricRequestDuration, time.Since(start).Seconds(), route, method)
+
+			return nil
+		}
+	}
+}
+
+// MetricsHandler flushes the metrics after each invocation, lambda instances may never run again
+func MetricsHandler(metrics Metrics, handler lambda.Handler) lambda.Handler {
+	return lambdaHandler(func(ctx context.Context, payload []byte) ([]byte, error) {
+		defer func() { _ = metrics.Flush() }()
+
+		return handler.Invoke(ctx, payload)
+	})
+}
+
+// isNotFound tells whether the router found no route for the request
+func isNotFound(handler echo.HandlerFunc) bool {
+	return reflect.ValueOf(handler).Pointer() == reflect.ValueOf(echo.NotFoundHandler).Pointer()
+}
+
+type lambdaHandler func(ctx context.Context, payload []byte) ([]byte, error)
+
+func (h lambdaHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return h(ctx, payload)
+}
+
+// labelValues pairs the label names of metric with values, missing values are empty
+func labelValues(metric Metric, values []string) map[string]string {
+	labels := make(map[string]string, len(metric.Labels))
+	for i, name := range metric.Labels {
+		if i < len(values) {
+			labels[name] = values[i]
+		} else {
+			labels[name] = ""
+		}
+	}
+
+	return labels
+}
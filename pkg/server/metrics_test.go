@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emfLines decodes the lines written by an EMF flush
+func emfLines(t *testing.T, output string) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded))
+		lines = append(lines, decoded)
+	}
+
+	return lines
+}
+
+func TestEMFMetrics_Flush(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	metrics := NewEMFMetrics("test", &buf)
+	metrics.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	metrics.Add(MetricChallenges, 1, "1")
+	metrics.Add(MetricChallenges, 1, "1")
+	metrics.Add(MetricChallenges, 1, "137")
+	metrics.Add(MetricAuthAttempts, 1, "authorize", "success")
+	metrics.Observe(MetricDBQueryDuration, 0.02, "Query")
+	metrics.Observe(MetricDBQueryDuration, 0.04, "Query")
+	metrics.Collect(func(m Metrics) {
+		m.Set(MetricDBConnectionWaits, 3)
+	})
+
+	require.NoError(t, metrics.Flush())
+
+	lines := emfLines(t, buf.String())
+	require.Len(t, lines, 5)
+
+	// a line per label set, sorted by labels
+	assert.Equal(t, 3.0, lines[0]["db_connection_waits"])
+
+	assert.Equal(t, "1", lines[1]["chain_id"])
+	assert.Equal(t, 2.0, lines[1]["auth_challenges_total"])
+	assert.Equal(t, "137", lines[2]["chain_id"])
+	assert.Equal(t, 1.0, lines[2]["auth_challenges_total"])
+
+	assert.Equal(t, "authorize", lines[3]["method"])
+	assert.Equal(t, "success", lines[3]["result"])
+	// CloudWatch rejects empty dimensions
+	assert.Equal(t, "none", lines[3]["reason"])
+	assert.Equal(t, 1.0, lines[3]["auth_attempts_total"])
+
+	assert.Equal(t, "Query", lines[4]["statement"])
+	assert.Equal(t, []interface{}{0.02, 0.04}, lines[4]["db_query_duration_seconds"])
+
+	metadata := lines[4]["_aws"].(map[string]interface{})
+	assert.Equal(t, 1700000000000.0, metadata["Timestamp"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"Namespace":  "test",
+		"Dimensions": []interface{}{[]interface{}{"statement"}},
+		"Metrics":    []interface{}{map[string]interface{}{"Name": "db_query_duration_seconds", "Unit": "Seconds"}},
+	}}, metadata["CloudWatchMetrics"])
+
+	// flushed metrics start over, collectors run on every flush
+	buf.Reset()
+	require.NoError(t, metrics.Flush())
+	lines = emfLines(t, buf.String())
+	require.Len(t, lines, 1)
+	assert.Equal(t, 3.0, lines[0]["db_connection_waits"])
+}
+
+func TestEMFMetrics_SplitsSamples(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	metrics := NewEMFMetrics("test", &buf)
+
+	metrics.Add(MetricDBQueryErrors, 1, "Exec")
+	for i := 0; i < 250; i++ {
+		metrics.Observe(MetricDBQueryDuration, 0.01, "Exec")
+	}
+
+	require.NoError(t, metrics.Flush())
+
+	lines := emfLines(t, buf.String())
+	require.Len(t, lines, 3)
+
+	// counters are only written once
+	assert.Equal(t, 1.0, lines[0]["db_query_errors_total"])
+	assert.NotContains(t, lines[1], "db_query_errors_total")
+
+	assert.Len(t, lines[0]["db_query_duration_seconds"], 100)
+	assert.Len(t, lines[1]["db_query_duration_seconds"], 100)
+	assert.Len(t, lines[2]["db_query_duration_seconds"], 50)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewPrometheusMetrics()
+	metrics.Add(MetricAuthAttempts, 1, "authorize", "failure", "3001")
+	metrics.Observe(MetricDBQueryDuration, 0.02, "Query")
+	metrics.Collect(func(m Metrics) {
+		m.Set(MetricDBConnections, 2, "in_use")
+	})
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `auth_attempts_total{method="authorize",reason="3001",result="failure"} 1`)
+	assert.Contains(t, body, `db_query_duration_seconds_count{statement="Query"} 1`)
+	assert.Contains(t, body, `db_connections{state="in_use"} 2`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	metrics := NewEMFMetrics("test", &buf)
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, metrics, DefaultCORSConfig)
+
+	e.GET("/users/:id", func(c echo.Context) error {
+		if c.Param("id") == "usr_missing" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic(errors.New("boom"))
+	})
+
+	for _, path := range []string{"/users/usr_1", "/users/usr_2", "/users/usr_missing", "/panic", "/unknown"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.NoError(t, metrics.Flush())
+
+	requests := map[string]float64{}
+	for _, line := range emfLines(t, buf.String()) {
+		if count, ok := line["http_requests_total"].(float64); ok {
+			requests[line["route"].(string)+" "+line["status"].(string)] = count
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"/users/:id 204": 2,
+		"/users/:id 404": 1,
+		"/panic 500":     1,
+		"unmatched 404":  1,
+	}, requests)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	metrics := NewEMFMetrics("test", &buf)
+
+	handler := MetricsHandler(metrics, lambdaHandler(func(ctx context.Context, payload []byte) ([]byte, error) {
+		metrics.Add(MetricChallenges, 1, "1")
+		return payload, nil
+	}))
+
+	_, err := handler.Invoke(context.Background(), []byte("{}"))
+	require.NoError(t, err)
+
+	output, err := io.ReadAll(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "auth_challenges_total")
+}
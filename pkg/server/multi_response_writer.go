@@ -1,35 +1,75 @@
 package server
 
 import (
-	"io/ioutil"
+	"bytes"
+	"io"
 	"net/http"
-	"net/http/httptest"
 )
 
+// capture keeps the first limit bytes written to it and counts the rest
+type capture struct {
+	buf   bytes.Buffer
+	limit int
+	size  int64
+}
+
+func (c *capture) write(p []byte) {
+	c.size += int64(len(p))
+
+	if room := c.limit - c.buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		c.buf.Write(p)
+	}
+}
+
+// Bytes returns the captured bytes, all of them when Size is no more than their length
+func (c *capture) Bytes() []byte {
+	return c.buf.Bytes()
+}
+
+// Size returns the number of bytes written, captured or not
+func (c *capture) Size() int64 {
+	return c.size
+}
+
 type ResponseWriterRecorder struct {
-	recorder *httptest.ResponseRecorder
+	capture
 	original http.ResponseWriter
 }
 
-func (mw ResponseWriterRecorder) Header() http.Header {
+func (mw *ResponseWriterRecorder) Header() http.Header {
 	return mw.original.Header()
 }
 
-func (mw ResponseWriterRecorder) Write(bytes []byte) (int, error) {
-	mw.recorder.Write(bytes)
+func (mw *ResponseWriterRecorder) Write(bytes []byte) (int, error) {
+	mw.write(bytes)
 	return mw.original.Write(bytes)
 }
 
-func (mw ResponseWriterRecorder) WriteHeader(statusCode int) {
-	mw.recorder.WriteHeader(statusCode)
+func (mw *ResponseWriterRecorder) WriteHeader(statusCode int) {
 	mw.original.WriteHeader(statusCode)
 }
 
-func (mw ResponseWriterRecorder) Body() string {
-	b, _ := ioutil.ReadAll(mw.recorder.Body)
-	return string(b)
+// NewResponseWriterRecorder copies up to limit bytes of the response body
+func NewResponseWriterRecorder(original http.ResponseWriter, limit int) *ResponseWriterRecorder {
+	return &ResponseWriterRecorder{capture{limit: limit}, original}
+}
+
+// BodyRecorder copies up to limit bytes of what is read from a request body
+type BodyRecorder struct {
+	capture
+	io.ReadCloser
+}
+
+func (r *BodyRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.write(p[:n])
+
+	return n, err
 }
 
-func NewResponseWriterRecorder(original http.ResponseWriter) *ResponseWriterRecorder {
-	return &ResponseWriterRecorder{httptest.NewRecorder(), original}
+func NewBodyRecorder(body io.ReadCloser, limit int) *BodyRecorder {
+	return &BodyRecorder{capture{limit: limit}, body}
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"sync"
+)
+
+// PrometheusMetrics keeps the metrics in a Prometheus registry and serves them to scrapers
+type PrometheusMetrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+	collectors []func(Metrics)
+}
+
+// NewPrometheusMetrics creates a registry holding the metrics of the process and of the go runtime
+func NewPrometheusMetrics() *PrometheusMetrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+	return &PrometheusMetrics{
+		registry:   registry,
+		handler:    promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+		counters:   map[string]*prometheus.CounterVec{},
+		histograms: map[string]*prometheus.HistogramVec{},
+		gauges:     map[string]*prometheus.GaugeVec{},
+	}
+}
+
+func (m *PrometheusMetrics) Add(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	vec, ok := m.counters[metric.Name]
+	if !ok {
+		vec = prometheus.NewCounterVec(prometheus.CounterOpts{Name: metric.Name, Help: metric.Help}, metric.Labels)
+		m.registry.MustRegister(vec)
+		m.counters[metric.Name] = vec
+	}
+	m.mu.Unlock()
+
+	vec.With(labelValues(metric, labels)).Add(value)
+}
+
+func (m *PrometheusMetrics) Observe(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	vec, ok := m.histograms[metric.Name]
+	if !ok {
+		vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: metric.Name, Help: metric.Help}, metric.Labels)
+		m.registry.MustRegister(vec)
+		m.histograms[metric.Name] = vec
+	}
+	m.mu.Unlock()
+
+	vec.With(labelValues(metric, labels)).Observe(value)
+}
+
+func (m *PrometheusMetrics) Set(metric Metric, value float64, labels ...string) {
+	m.mu.Lock()
+	vec, ok := m.gauges[metric.Name]
+	if !ok {
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: metric.Name, Help: metric.Help}, metric.Labels)
+		m.registry.MustRegister(vec)
+		m.gauges[metric.Name] = vec
+	}
+	m.mu.Unlock()
+
+	vec.With(labelValues(metric, labels)).Set(value)
+}
+
+func (m *PrometheusMetrics) Collect(fn func(Metrics)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectors = append(m.collectors, fn)
+}
+
+// Flush does nothing, Prometheus pulls the metrics
+func (m *PrometheusMetrics) Flush() error {
+	return nil
+}
+
+// ServeHTTP samples the collected gauges and writes every metric in the Prometheus text format
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	collectors := append([]func(Metrics){}, m.collectors...)
+	m.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect(m)
+	}
+
+	m.handler.ServeHTTP(w, r)
+}
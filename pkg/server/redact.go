@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the values hidden from the logs
+const Redacted = "[REDACTED]"
+
+// maxCapturedBody bounds the memory used to log a body. Bodies are redacted whole, larger ones aren't logged
+const maxCapturedBody = 64 << 10
+
+// LogConfig controls what LoggerMiddleware logs
+type LogConfig struct {
+	// RedactFields are paths of JSON fields, such as "signature" or "user.token". A path matches the fields whose
+	// path ends with it, "*" matches any key or index
+	RedactFields []string
+	// RedactHeaders are request headers whose values are hidden
+	RedactHeaders []string
+	// RedactForm are form fields and query parameters whose values are hidden
+	RedactForm []string
+	// RedactPatterns hide what they match anywhere in bodies and urls, whatever the content type
+	RedactPatterns []*regexp.Regexp
+	// MaxBodySize truncates logged bodies once redacted, 0 doesn't log them
+	MaxBodySize int
+	// OmitBodies are routes, such as "/auth/authorize", whose bodies are never logged
+	OmitBodies []string
+	// SkipRoutes are routes whose successful requests aren't logged
+	SkipRoutes []string
+	// SuccessSampleRate is the share of successful requests logged, between 0 and 1. Failures are always logged
+	SuccessSampleRate float64
+}
+
+// DefaultLogConfig hides signatures, tokens and credentials
+var DefaultLogConfig = LogConfig{
+	RedactFields:  []string{"signature", "token", "password", "secret"},
+	RedactHeaders: []string{echo.HeaderAuthorization, echo.HeaderCookie, echo.HeaderSetCookie, "X-Api-Key"},
+	RedactForm:    []string{"signature", "token", "password", "secret"},
+	RedactPatterns: []*regexp.Regexp{
+		// JWTs
+		regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		// ethereum signatures
+		regexp.MustCompile(`(0x)?[0-9a-fA-F]{130}`),
+	},
+	MaxBodySize:       4 << 10,
+	SuccessSampleRate: 1,
+}
+
+// redactor hides the sensitive parts of what is logged
+type redactor struct {
+	fields   [][]string
+	headers  map[string]bool
+	form     map[string]bool
+	patterns []*regexp.Regexp
+	maxBody  int
+}
+
+func newRedactor(config LogConfig) *redactor {
+	r := &redactor{
+		headers:  map[string]bool{},
+		form:     map[string]bool{},
+		patterns: config.RedactPatterns,
+		maxBody:  config.MaxBodySize,
+	}
+
+	for _, field := range config.RedactFields {
+		r.fields = append(r.fields, strings.Split(field, "."))
+	}
+	for _, header := range config.RedactHeaders {
+		r.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	for _, field := range config.RedactForm {
+		r.form[field] = true
+	}
+
+	return r
+}
+
+// Headers returns the headers to log
+func (r *redactor) Headers(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for key, values := range header {
+		value := strings.Join(values, ", ")
+		if r.headers[http.CanonicalHeaderKey(key)] {
+			value = Redacted
+		}
+
+		result[key] = r.String(value)
+	}
+
+	return result
+}
+
+// URL returns the url to log
+func (r *redactor) URL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return r.String(u.String())
+	}
+
+	redacted := *u
+	redacted.RawQuery = r.formValues(u.Query()).Encode()
+
+	return r.String(redacted.String())
+}
+
+// Body returns the body to log. size is its full size, the body is only complete when it's no more than len(body)
+func (r *redactor) Body(contentType string, body []byte, size int64) string {
+	if r.maxBody <= 0 || size == 0 {
+		return ""
+	}
+
+	if size > int64(len(body)) {
+		return fmt.Sprintf("[body of %d bytes omitted]", size)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if redacted, ok := r.json(body); ok {
+			body = redacted
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			body = []byte(r.formValues(values).Encode())
+		}
+	}
+
+	return r.truncate(r.String(string(body)))
+}
+
+func (r *redactor) truncate(s string) string {
+	if len(s) <= r.maxBody {
+		return s
+	}
+
+	return s[:r.maxBody] + "...[truncated " + strconv.Itoa(len(s)-r.maxBody) + " bytes]"
+}
+
+// String hides what the patterns match in s
+func (r *redactor) String(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+
+	return s
+}
+
+func (r *redactor) formValues(values url.Values) url.Values {
+	result := url.Values{}
+	for key, v := range values {
+		if r.form[key] {
+			result[key] = []string{Redacted}
+			continue
+		}
+
+		result[key] = v
+	}
+
+	return result
+}
+
+// json hides the configured fields, it reports false when body isn't JSON
+func (r *redactor) json(body []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	redacted, err := json.Marshal(r.walk(nil, value))
+	if err != nil {
+		return nil, false
+	}
+
+	return redacted, true
+}
+
+func (r *redactor) walk(path []string, value interface{}) interface{} {
+	if r.matches(path) {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = r.walk(append(path[:len(path):len(path)], key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.walk(append(path[:len(path):len(path)], strconv.Itoa(i)), child)
+		}
+	}
+
+	return value
+}
+
+// matches reports whether a field path ends with one of the configured paths
+func (r *redactor) matches(path []string) bool {
+	for _, field := range r.fields {
+		if len(field) > len(path) {
+			continue
+		}
+
+		suffix := path[len(path)-len(field):]
+		matched := true
+		for i, segment := range field {
+			if segment != "*" && segment != suffix[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,22 +1,25 @@
 package server
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/labstack/echo/v4"
-	"io/ioutil"
-	"net/http"
+	"math/rand"
 	"time"
 
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/httperror"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.uber.org/zap"
 )
 
-// NewEcho creates an echo instance with defaults
-func NewEcho(logger *zap.SugaredLogger, allowedOrigins ...string) *echo.Echo {
+// NewEcho creates an echo instance with defaults. Paths prefixed with one of stages are routed without it
+func NewEcho(logger *zap.SugaredLogger, logConfig LogConfig, metrics Metrics, corsConfig CORSConfig, stages ...string) *echo.Echo {
 	e := echo.New()
 
 	// remove logs generated by echo because it's not compatible with zap
@@ -24,26 +27,35 @@ func NewEcho(logger *zap.SugaredLogger, allowedOrigins ...string) *echo.Echo {
 	e.HidePort = true
 	e.Logger.SetLevel(log.OFF)
 
+	// trace requests, first so the request logs carry the trace ID
+	e.Use(otelecho.Middleware(tracing.ServiceName))
+	// count requests, their status is only known once LoggerMiddleware handled the error
+	e.Use(MetricsMiddleware(metrics))
 	// log requests/response
-	e.Use(LoggerMiddleware(logger))
+	e.Use(LoggerMiddleware(logger, logConfig))
 	// recover from panic inside a handler
 	e.Use(RecoverMiddleware())
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: allowedOrigins,
-		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-	}))
+	e.Use(CORSMiddleware(corsConfig))
 
 	e.HTTPErrorHandler = httperror.NewErrorHandler(logger)
 
-	e.Pre(middleware.Rewrite(map[string]string{
-		"/prod/*": "/$1",
-		"/dev/*":  "/$1",
-	}))
+	if len(stages) > 0 {
+		e.Pre(StageRewrite(stages))
+	}
 
 	return e
 }
 
+// StageRewrite removes the API Gateway stage prefixing paths, /prod/users/me is routed as /users/me
+func StageRewrite(stages []string) echo.MiddlewareFunc {
+	rules := make(map[string]string, len(stages))
+	for _, stage := range stages {
+		rules["/"+stage+"/*"] = "/$1"
+	}
+
+	return middleware.Rewrite(rules)
+}
+
 // RecoverMiddleware will catch any panics from the call stack. Must be registered after LoggerMiddleware to benefit from proper logging
 func RecoverMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -68,57 +80,102 @@ func RecoverMiddleware() echo.MiddlewareFunc {
 	}
 }
 
-// LoggerMiddleware logs all request/responses
-func LoggerMiddleware(logger *zap.SugaredLogger) echo.MiddlewareFunc {
+// LoggerMiddleware logs all request/responses, redacted as config says
+func LoggerMiddleware(logger *zap.SugaredLogger, config LogConfig) echo.MiddlewareFunc {
+	redact := newRedactor(config)
+	skipped := routeSet(config.SkipRoutes)
+	omitted := routeSet(config.OmitBodies)
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			id := uuid.New().String()
+			id := requestID(c)
 			req := c.Request()
 			res := c.Response()
 			start := time.Now()
-			url := req.URL.String()
+			url := redact.URL(req.URL)
 
-			// read the body payload into memory so we can log it later
-			body, err := ioutil.ReadAll(req.Body)
+			// clients quote it when reporting a problem, error responses use it as instance
+			res.Header().Set(logging.HeaderRequestID, id)
 
-			if err != nil {
-				logger.Error("unable to read body into memory", zap.Error(err))
-			}
-
-			req.Body.Close()
-
-			// create a new read closer from the in-memory payload so the next handler can read it
-			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			// services log with the request and trace IDs and forward the request ID to the services they call
+			reqLogger := logger.With(append([]interface{}{"request_id", id}, tracing.LogFields(req.Context())...)...)
+			req = req.WithContext(logging.WithLogger(logging.WithRequestID(req.Context(), id), reqLogger))
+			c.SetRequest(req)
 
-			// create a response writer that copies the response body into memory to log later
-			recorder := NewResponseWriterRecorder(res.Writer)
+			// copy the bodies as they're read and written, up to a bound, so we can log them later
+			body := NewBodyRecorder(req.Body, maxCapturedBody)
+			req.Body = body
+			recorder := NewResponseWriterRecorder(res.Writer, maxCapturedBody)
 			res.Writer = recorder
 
-			err = next(c)
+			err := next(c)
 
 			if err != nil {
 				c.Error(err)
+			} else if skipped[c.Path()] || rand.Float64() >= config.SuccessSampleRate {
+				return nil
 			}
 
 			stop := time.Now()
 
 			fields := []interface{}{
-				zap.String("request_id", id),
-				zap.ByteString("request_body", body),
-				zap.String("response_body", recorder.Body()),
+				zap.Any("request_headers", redact.Headers(req.Header)),
 				zap.Int64("latency", stop.Sub(start).Milliseconds()),
 				zap.String("url", url),
 			}
 
+			if !omitted[c.Path()] {
+				fields = append(fields,
+					zap.String("request_body", redact.Body(req.Header.Get(echo.HeaderContentType), body.Bytes(), body.Size())),
+					zap.String("response_body", redact.Body(res.Header().Get(echo.HeaderContentType), recorder.Bytes(), recorder.Size())),
+				)
+			}
+
 			if err != nil {
-				msg := fmt.Sprintf("%d %s %s - %s\n", res.Status, req.Method, c.Path(), err)
-				logger.Warnw(msg, fields...)
+				// the cause and its stack trace only go to the logs
+				fields = append(fields, zap.String("error", redact.String(fmt.Sprintf("%+v", err))))
+				msg := redact.String(fmt.Sprintf("%d %s %s - %s\n", res.Status, req.Method, c.Path(), err))
+
+				switch httperror.SeverityOf(err) {
+				case domain.SeverityInfo:
+					reqLogger.Infow(msg, fields...)
+				case domain.SeverityWarn:
+					reqLogger.Warnw(msg, fields...)
+				default:
+					reqLogger.Errorw(msg, fields...)
+				}
 			} else {
 				msg := fmt.Sprintf("%d %s %s", res.Status, req.Method, c.Path())
-				logger.Debugw(msg, fields...)
+				reqLogger.Debugw(msg, fields...)
 			}
 
 			return nil
 		}
 	}
 }
+
+func routeSet(routes []string) map[string]bool {
+	set := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		set[route] = true
+	}
+
+	return set
+}
+
+// requestID returns the ID a client or API Gateway gave to the request, or a new one
+func requestID(c echo.Context) string {
+	if id := c.Request().Header.Get(logging.HeaderRequestID); logging.ValidRequestID(id) {
+		return id
+	}
+
+	ctx := c.Request().Context()
+	if apiGateway, ok := core.GetAPIGatewayContextFromContext(ctx); ok && apiGateway.RequestID != "" {
+		return apiGateway.RequestID
+	}
+	if apiGateway, ok := core.GetAPIGatewayV2ContextFromContext(ctx); ok && apiGateway.RequestID != "" {
+		return apiGateway.RequestID
+	}
+
+	return uuid.New().String()
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerMiddleware_RequestID(t *testing.T) {
+	t.Parallel()
+
+	e := NewEcho(zap.NewNop().Sugar(), DefaultLogConfig, NopMetrics, DefaultCORSConfig)
+
+	var seen string
+	e.GET("/ping", func(c echo.Context) error {
+		seen = logging.RequestID(c.Request().Context())
+		assert.NotNil(t, logging.FromContext(c.Request().Context(), nil))
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		if id != "" {
+			req.Header.Set(logging.HeaderRequestID, id)
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// the ID of the client is kept
+	rec := serve("client-req-1")
+	assert.Equal(t, "client-req-1", rec.Header().Get(logging.HeaderRequestID))
+	assert.Equal(t, "client-req-1", seen)
+
+	// invalid IDs are replaced
+	rec = serve("bad id\n")
+	id := rec.Header().Get(logging.HeaderRequestID)
+	require.NotEmpty(t, id)
+	assert.NotEqual(t, "bad id\n", id)
+	assert.Equal(t, id, seen)
+
+	// requests without one get a new ID
+	rec = serve("")
+	assert.NotEmpty(t, rec.Header().Get(logging.HeaderRequestID))
+}
+
+func TestNewEcho_Tracing(t *testing.T) {
+	provider, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterMemory, SampleRate: 1})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	e, logs := newTestEcho(DefaultLogConfig)
+
+	e.GET("/users/:id", func(c echo.Context) error {
+		_, span := tracing.Start(c.Request().Context(), "UserService.Get")
+		span.End()
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// the caller started the trace
+	req := httptest.NewRequest(http.MethodGet, "/users/usr_1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := provider.Spans.GetSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "UserService.Get", spans[0].Name)
+	assert.Equal(t, "/users/:id", spans[1].Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID().String())
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+
+	// the request logs link to the trace
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.Equal(t, spans[1].SpanContext.SpanID().String(), entry["span_id"])
+}
@@ -1,16 +1,26 @@
 package service
 
 import (
+	"context"
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/manta-coder/golang-serverless-example/pkg/auth"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
 	"github.com/manta-coder/golang-serverless-example/pkg/store"
 	"go.uber.org/zap"
+	"strconv"
+	"time"
 )
 
+// LoginEnsRefreshTimeout bounds the ens refresh of users logging in for the first time
+const LoginEnsRefreshTimeout = 2 * time.Second
+
 type AuthService interface {
-	Challenge(input auth.ChallengeInput) (auth.ChallengeOutput, error)
-	Authorize(input auth.AuthorizeInput) (auth.AuthorizeOutput, error)
-	AuthorizeSilently(input auth.AuthorizeSilentlyInput) (auth.AuthorizeOutput, error)
+	Challenge(ctx context.Context, input auth.ChallengeInput) (auth.ChallengeOutput, error)
+	Authorize(ctx context.Context, input auth.AuthorizeInput) (auth.AuthorizeOutput, error)
+	AuthorizeSilently(ctx context.Context, input auth.AuthorizeSilentlyInput) (auth.AuthorizeOutput, error)
 }
 
 type authService struct {
@@ -18,64 +28,112 @@ type authService struct {
 	auth           *auth.Service
 	challengeStore store.ChallengeStore
 	userService    UserService
+	metrics        server.Metrics
 }
 
-func NewAuthService(logger *zap.SugaredLogger, auth *auth.Service, challengeStore store.ChallengeStore, userService UserService) AuthService {
-	return &authService{logger, auth, challengeStore, userService}
+func NewAuthService(logger *zap.SugaredLogger, auth *auth.Service, challengeStore store.ChallengeStore, userService UserService, metrics server.Metrics) AuthService {
+	return &authService{logger, auth, challengeStore, userService, metrics}
 }
 
-func (s *authService) Challenge(input auth.ChallengeInput) (auth.ChallengeOutput, error) {
+func (s *authService) Challenge(ctx context.Context, input auth.ChallengeInput) (auth.ChallengeOutput, error) {
+	address, err := s.userService.ResolveAddress(ctx, input.EthereumAddressHex)
+	if err != nil {
+		return auth.ChallengeOutput{}, err
+	}
+
+	input.EthereumAddressHex = address.Hex()
+
+	// clients predating multi-chain support don't send a chain
+	if input.ChainID == 0 {
+		input.ChainID = s.auth.DefaultChainID()
+	}
+
 	if err := input.Validate(); err != nil {
 		return auth.ChallengeOutput{}, err
 	}
+	if err := s.auth.ValidateChainID(input.ChainID); err != nil {
+		return auth.ChallengeOutput{}, err
+	}
 
-	address := input.Address()
+	wallet := input.Wallet()
 	challenge := s.auth.NewChallenge()
 
-	if _, err := s.challengeStore.Store(domain.Challenge{
-		EthereumAddressHex: address.Hex(),
-		Challenge:          challenge,
+	if _, err := s.challengeStore.Store(ctx, domain.Challenge{
+		EthereumAddress: wallet.Address,
+		ChainID:         wallet.ChainID,
+		Challenge:       challenge,
 	}); err != nil {
-		return auth.ChallengeOutput{}, domain.ErrChallengeStoreFailed(err)
+		return auth.ChallengeOutput{}, storeError(err, domain.ErrChallengeStoreFailed)
 	}
 
-	return auth.NewChallengeOutput(challenge), nil
+	s.metrics.Add(server.MetricChallenges, 1, strconv.FormatInt(int64(wallet.ChainID), 10))
+
+	return auth.NewChallengeOutput(auth.ChallengeMessage(challenge, wallet.ChainID)), nil
 }
 
-func (s *authService) Authorize(input auth.AuthorizeInput) (auth.AuthorizeOutput, error) {
+func (s *authService) Authorize(ctx context.Context, input auth.AuthorizeInput) (auth.AuthorizeOutput, error) {
+	output, err := s.authorize(ctx, input)
+	s.countAttempt("authorize", err)
+
+	return output, err
+}
+
+func (s *authService) authorize(ctx context.Context, input auth.AuthorizeInput) (auth.AuthorizeOutput, error) {
+	resolved, err := s.userService.ResolveAddress(ctx, input.EthereumAddressHex)
+	if err != nil {
+		return auth.AuthorizeOutput{}, err
+	}
+
+	input.EthereumAddressHex = resolved.Hex()
+
+	if input.ChainID == 0 {
+		input.ChainID = s.auth.DefaultChainID()
+	}
+
 	if err := input.Validate(); err != nil {
 		return auth.AuthorizeOutput{}, err
 	}
 
-	address := input.Address()
+	wallet := input.Wallet()
+	address := wallet.Address
 	sig := input.Signature()
 
-	challenge, err := s.challengeStore.Get(address.Hex())
+	challenge, err := s.challengeStore.Get(ctx, address, wallet.ChainID)
 	if err != nil {
-		return auth.AuthorizeOutput{}, domain.ErrChallengeGetFailed(err)
+		return auth.AuthorizeOutput{}, storeError(err, domain.ErrChallengeGetFailed)
 	}
 
-	verifyErr := s.auth.VerifyChallenge(challenge, sig.Bytes())
-	if err = s.challengeStore.Remove(address.Hex()); err != nil {
-		return auth.AuthorizeOutput{}, domain.ErrChallengeRemoveFailed(err)
+	verifyErr := s.auth.VerifyChallenge(challenge, wallet.ChainID, sig.Bytes())
+	if err = s.challengeStore.Remove(ctx, address, wallet.ChainID); err != nil {
+		return auth.AuthorizeOutput{}, storeError(err, domain.ErrChallengeRemoveFailed)
 	}
 	if verifyErr != nil {
 		return auth.AuthorizeOutput{}, verifyErr
 	}
 
-	user, err := s.userService.FindByEthereumAddress(address.Hex())
+	user, err := s.userService.FindByEthereumAddress(ctx, address)
 	if err != nil {
-		return auth.AuthorizeOutput{}, domain.ErrUserFindByEthereumAddressFailed(err)
+		return auth.AuthorizeOutput{}, err
 	}
 
 	if user.UserID == "" {
-		user, err = s.userService.Store(domain.NewUserStoreInput(address.Hex(), ""))
+		user, err = s.userService.Store(ctx, domain.NewUserStoreInput(address.Hex(), ""))
 		if err != nil {
-			return auth.AuthorizeOutput{}, domain.ErrUserStoreFailed(err)
+			return auth.AuthorizeOutput{}, err
 		}
 	}
 
-	tokenBytes, err := s.auth.IssueToken(user)
+	// new users get their ENS profile right away instead of waiting for the scheduled refresh. A slow node must not
+	// hold up logins, the refresh job picks up the users it didn't answer for
+	if user.EnsRefreshedAt == nil {
+		refreshCtx, cancel := context.WithTimeout(ctx, LoginEnsRefreshTimeout)
+		if _, err = s.userService.RefreshEns(refreshCtx, user); err != nil {
+			logging.FromContext(ctx, s.logger).Warnw("unable to refresh ens profile", "user_id", user.UserID, "err", err)
+		}
+		cancel()
+	}
+
+	tokenBytes, err := s.auth.IssueToken(user, wallet.ChainID)
 	if err != nil {
 		return auth.AuthorizeOutput{}, err
 	}
@@ -83,20 +141,55 @@ func (s *authService) Authorize(input auth.AuthorizeInput) (auth.AuthorizeOutput
 	return auth.NewAuthorizeOutput(string(tokenBytes)), nil
 }
 
-func (s *authService) AuthorizeSilently(input auth.AuthorizeSilentlyInput) (auth.AuthorizeOutput, error) {
+func (s *authService) AuthorizeSilently(ctx context.Context, input auth.AuthorizeSilentlyInput) (auth.AuthorizeOutput, error) {
+	output, err := s.authorizeSilently(ctx, input)
+	s.countAttempt("authorize_silently", err)
+
+	return output, err
+}
+
+func (s *authService) authorizeSilently(ctx context.Context, input auth.AuthorizeSilentlyInput) (auth.AuthorizeOutput, error) {
 	if err := input.Validate(); err != nil {
 		return auth.AuthorizeOutput{}, err
 	}
+	if err := s.auth.ValidateChainID(input.ChainID); err != nil {
+		return auth.AuthorizeOutput{}, err
+	}
 
-	user, err := s.userService.FindByEthereumAddress(input.EthereumAddressHex)
+	user, err := s.userService.FindByEthereumAddress(ctx, input.Address())
 	if err != nil {
-		return auth.AuthorizeOutput{}, domain.ErrUserFindByEthereumAddressFailed(err)
+		return auth.AuthorizeOutput{}, err
 	}
 
-	tokenBytes, err := s.auth.IssueToken(user)
+	tokenBytes, err := s.auth.IssueToken(user, input.ChainID)
 	if err != nil {
 		return auth.AuthorizeOutput{}, err
 	}
 
 	return auth.NewAuthorizeOutput(string(tokenBytes)), nil
 }
+
+// countAttempt counts an authorization by result, failures by the code of their error
+func (s *authService) countAttempt(method string, err error) {
+	if err == nil {
+		s.metrics.Add(server.MetricAuthAttempts, 1, method, "success", "")
+		return
+	}
+
+	s.metrics.Add(server.MetricAuthAttempts, 1, method, "failure", failureReason(err))
+}
+
+// failureReason returns the error code of err, clients get the same one
+func failureReason(err error) string {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return strconv.Itoa(domainErr.Code)
+	}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		return "invalid_input"
+	}
+
+	return "unknown"
+}
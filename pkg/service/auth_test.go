@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -8,6 +9,8 @@ import (
 	"github.com/golang-jwt/jwt"
 	"github.com/manta-coder/golang-serverless-example/pkg/auth"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/ens"
+	"github.com/manta-coder/golang-serverless-example/pkg/server"
 	"github.com/manta-coder/golang-serverless-example/pkg/store"
 	"github.com/manta-coder/golang-serverless-example/pkg/tester"
 	"github.com/stretchr/testify/assert"
@@ -31,7 +34,7 @@ func createTestAuth() *auth.Service {
 var testAuth = createTestAuth()
 
 func createTestAuthService() AuthService {
-	return NewAuthService(tester.GetLogger(), testAuth, testChallengeStore, testUserService)
+	return NewAuthService(tester.GetLogger(), testAuth, testChallengeStore, testUserService, server.NopMetrics)
 }
 
 var testAuthService = createTestAuthService()
@@ -39,13 +42,15 @@ var testAuthService = createTestAuthService()
 func TestAuthService_Challenge(t *testing.T) {
 	user := testUser(t)
 
-	createdChallenge, err := testAuthService.Challenge(auth.NewChallengeInput(user.EthereumAddressHex))
+	// clients that don't send a chain get a mainnet challenge
+	createdChallenge, err := testAuthService.Challenge(context.Background(), auth.NewChallengeInput(user.EthereumAddress.Hex(), 0))
 	require.NoError(t, err)
 
-	foundChallenge, err := testChallengeStore.Get(user.EthereumAddressHex)
+	foundChallenge, err := testChallengeStore.Get(context.Background(), user.EthereumAddress, domain.ChainIDMainnet)
 	require.NoError(t, err)
 
-	assert.Equal(t, createdChallenge.Challenge, foundChallenge.Challenge)
+	assert.Equal(t, auth.ChallengeMessage(foundChallenge.Challenge, domain.ChainIDMainnet), createdChallenge.Challenge)
+	assert.Contains(t, createdChallenge.Challenge, "Chain ID: 1\n")
 }
 
 func TestAuthService_AuthorizeService(t *testing.T) {
@@ -57,7 +62,7 @@ func TestAuthService_AuthorizeService(t *testing.T) {
 
 	addressHex := domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA)).Hex()
 
-	createdChallenge, err := testAuthService.Challenge(auth.NewChallengeInput(addressHex))
+	createdChallenge, err := testAuthService.Challenge(context.Background(), auth.NewChallengeInput(addressHex, domain.ChainIDMainnet))
 	require.NoError(t, err)
 
 	signedHash := tester.SignHash(createdChallenge.Challenge)
@@ -65,7 +70,7 @@ func TestAuthService_AuthorizeService(t *testing.T) {
 	signatureBytes, err := crypto.Sign(signedHash.Bytes(), privateKey)
 	require.NoError(t, err)
 
-	token, err := testAuthService.Authorize(auth.NewAuthorizeInput(addressHex, hexutil.Encode(signatureBytes)))
+	token, err := testAuthService.Authorize(context.Background(), auth.NewAuthorizeInput(addressHex, domain.ChainIDMainnet, hexutil.Encode(signatureBytes)))
 	require.NoError(t, err)
 
 	keyFunc := func(t *jwt.Token) (interface{}, error) {
@@ -83,13 +88,13 @@ func TestAuthService_AuthorizeService(t *testing.T) {
 
 	authClaims := parsedToken.Claims.(*auth.Claims)
 
-	assert.Equal(t, authClaims.EthereumAddressHex, addressHex)
+	assert.Equal(t, addressHex, authClaims.EthereumAddress.Hex())
 
 	// wrong signature should fail
 	privateKey2 := tester.CreatePrivateKey(t, "8")
 	signatureBytes2, err := crypto.Sign(signedHash.Bytes(), privateKey2)
 	assert.NoError(t, err)
-	_, err = testAuthService.Authorize(auth.NewAuthorizeInput(addressHex, hexutil.Encode(signatureBytes2)))
+	_, err = testAuthService.Authorize(context.Background(), auth.NewAuthorizeInput(addressHex, domain.ChainIDMainnet, hexutil.Encode(signatureBytes2)))
 	assert.Error(t, err)
 
 	// wrong public key should fail
@@ -97,6 +102,76 @@ func TestAuthService_AuthorizeService(t *testing.T) {
 	publicKeyECDSA2, ok := publicKey2.(*ecdsa.PublicKey)
 	addressHex2 := domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA2)).Hex()
 	assert.True(t, ok)
-	_, err = testAuthService.Authorize(auth.NewAuthorizeInput(addressHex2, hexutil.Encode(signatureBytes2)))
+	_, err = testAuthService.Authorize(context.Background(), auth.NewAuthorizeInput(addressHex2, domain.ChainIDMainnet, hexutil.Encode(signatureBytes2)))
 	assert.Error(t, err)
 }
+
+func TestAuthService_AuthorizeWithEnsName(t *testing.T) {
+	ctx := context.Background()
+	privateKey := tester.CreatePrivateKey(t, "7")
+
+	publicKey := privateKey.Public()
+	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
+	require.True(t, ok)
+
+	address := domain.EthereumAddress(crypto.PubkeyToAddress(*publicKeyECDSA))
+	testResolver.Register("login.eth", address.Hex(), "")
+
+	createdChallenge, err := testAuthService.Challenge(ctx, auth.NewChallengeInput("login.eth", domain.ChainIDMainnet))
+	require.NoError(t, err)
+
+	// the challenge is stored for the resolved address
+	foundChallenge, err := testChallengeStore.Get(context.Background(), address, domain.ChainIDMainnet)
+	require.NoError(t, err)
+	assert.Equal(t, auth.ChallengeMessage(foundChallenge.Challenge, domain.ChainIDMainnet), createdChallenge.Challenge)
+
+	signatureBytes, err := crypto.Sign(tester.SignHash(createdChallenge.Challenge).Bytes(), privateKey)
+	require.NoError(t, err)
+
+	_, err = testAuthService.Authorize(ctx, auth.NewAuthorizeInput("login.eth", domain.ChainIDMainnet, hexutil.Encode(signatureBytes)))
+	require.NoError(t, err)
+
+	// the ens profile of the user is refreshed on login
+	user, err := testUserService.FindByEthereumAddress(context.Background(), address)
+	require.NoError(t, err)
+	require.NotNil(t, user.EnsName)
+	assert.Equal(t, "login.eth", *user.EnsName)
+
+	_, err = testAuthService.Challenge(ctx, auth.NewChallengeInput("unknown.eth", domain.ChainIDMainnet))
+	assert.Error(t, err)
+}
+
+// slowResolver answers reverse lookups only once ctx is done, like a node that doesn't respond
+type slowResolver struct {
+	*ens.FakeResolver
+}
+
+func (r slowResolver) ReverseResolve(ctx context.Context, addressHex string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestAuthService_AuthorizeWithSlowResolver(t *testing.T) {
+	ctx := context.Background()
+	privateKey := tester.CreatePrivateKey(t, "6")
+	addressHex := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+
+	userService := NewUserService(tester.GetLogger(), testUserStore, slowResolver{ens.NewFakeResolver()})
+	authService := NewAuthService(tester.GetLogger(), testAuth, testChallengeStore, userService, server.NopMetrics)
+
+	createdChallenge, err := authService.Challenge(ctx, auth.NewChallengeInput(addressHex, domain.ChainIDMainnet))
+	require.NoError(t, err)
+
+	signatureBytes, err := crypto.Sign(tester.SignHash(createdChallenge.Challenge).Bytes(), privateKey)
+	require.NoError(t, err)
+
+	// the login doesn't wait for the node, the profile is left to the refresh job
+	started := time.Now()
+	_, err = authService.Authorize(ctx, auth.NewAuthorizeInput(addressHex, domain.ChainIDMainnet, hexutil.Encode(signatureBytes)))
+	require.NoError(t, err)
+	assert.Less(t, time.Since(started), LoginEnsRefreshTimeout+time.Second)
+
+	user, err := userService.FindByEthereumAddress(ctx, domain.EthereumAddress(crypto.PubkeyToAddress(privateKey.PublicKey)))
+	require.NoError(t, err)
+	assert.Nil(t, user.EnsRefreshedAt)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"errors"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+)
+
+// storeError maps a classified store failure to the matching domain error and any other failure to fallback
+func storeError(err error, fallback func(error) *domain.Error) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return domain.ErrNotFound(err)
+	case errors.Is(err, db.ErrConflict):
+		return domain.ErrConflict(err)
+	case errors.Is(err, db.ErrForeignKey), errors.Is(err, db.ErrCheckViolation):
+		return domain.ErrConstraintViolation(err)
+	case errors.Is(err, db.ErrTimeout):
+		return domain.ErrDatabaseTimeout(err)
+	default:
+		return fallback(err)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/chain"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/store"
+	"go.uber.org/zap"
+)
+
+// HoldingSource returns the number of tokens of a contract held by an address on a chain
+type HoldingSource interface {
+	BalanceOf(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error)
+}
+
+type indexedHoldingSource struct {
+	ownershipStore store.OwnershipStore
+}
+
+// NewIndexedHoldingSource reads balances from the ownerships maintained by the indexer
+func NewIndexedHoldingSource(ownershipStore store.OwnershipStore) HoldingSource {
+	return &indexedHoldingSource{ownershipStore}
+}
+
+func (s *indexedHoldingSource) BalanceOf(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error) {
+	return s.ownershipStore.CountByOwner(ctx, chainID, contractAddress, ownerAddress)
+}
+
+type chainHoldingSource struct {
+	readers chain.Readers
+}
+
+// NewChainHoldingSource reads balances from the nodes, it sees the tokens the indexer hasn't caught up with yet
+func NewChainHoldingSource(readers chain.Readers) HoldingSource {
+	return &chainHoldingSource{readers}
+}
+
+func (s *chainHoldingSource) BalanceOf(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error) {
+	return s.readers.BalanceOf(ctx, chainID, contractAddress, ownerAddress)
+}
+
+type HoldingService interface {
+	HoldsToken(ctx context.Context, wallet domain.Wallet, contractAddress domain.EthereumAddress, minBalance uint64) (bool, error)
+}
+
+type holdingService struct {
+	logger *zap.SugaredLogger
+	source HoldingSource
+}
+
+func NewHoldingService(logger *zap.SugaredLogger, source HoldingSource) HoldingService {
+	return &holdingService{logger, source}
+}
+
+// HoldsToken returns true if wallet holds at least minBalance tokens of contract on the wallet chain
+func (s *holdingService) HoldsToken(ctx context.Context, wallet domain.Wallet, contractAddress domain.EthereumAddress, minBalance uint64) (bool, error) {
+	if err := domain.ValidateChainID(wallet.ChainID); err != nil {
+		return false, err
+	}
+
+	balance, err := s.source.BalanceOf(ctx, wallet.ChainID, contractAddress, wallet.Address)
+	if err != nil {
+		return false, domain.ErrTokenHoldingCheckFailed(err)
+	}
+
+	return balance >= minBalance, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/indexer"
+	"github.com/manta-coder/golang-serverless-example/pkg/store"
+	"go.uber.org/zap"
+)
+
+const DefaultIndexerBatchSize = 1000
+
+type IndexerConfig struct {
+	// Name identifies the cursor of the indexer
+	Name string
+	// ChainID is the chain the source reads from
+	ChainID domain.ChainID
+	// Contracts are the ERC-721 contracts to index
+	Contracts []domain.EthereumAddress
+	// CharacterContract is the contract whose token IDs are used as character IDs, none when zero
+	CharacterContract domain.EthereumAddress
+	// StartBlock is the first block indexed when the indexer never ran
+	StartBlock uint64
+	// BatchSize is the number of blocks queried at once
+	BatchSize uint64
+	// Confirmations is the depth after which blocks are considered final. A reorg rolls back to this depth
+	Confirmations uint64
+}
+
+type IndexerService interface {
+	Sync(ctx context.Context) error
+}
+
+type indexerService struct {
+	logger         *zap.SugaredLogger
+	config         IndexerConfig
+	source         indexer.Source
+	ownershipStore store.OwnershipStore
+	userService    UserService
+}
+
+func NewIndexerService(logger *zap.SugaredLogger, config IndexerConfig, source indexer.Source, ownershipStore store.OwnershipStore, userService UserService) IndexerService {
+	if config.BatchSize == 0 {
+		config.BatchSize = DefaultIndexerBatchSize
+	}
+	if config.ChainID == 0 {
+		config.ChainID = domain.ChainIDMainnet
+	}
+
+	return &indexerService{logger, config, source, ownershipStore, userService}
+}
+
+// Sync ingests the transfers emitted since the last run, up to the latest block
+func (s *indexerService) Sync(ctx context.Context) error {
+	cursor, err := s.ownershipStore.GetCursor(ctx, s.config.Name)
+	if err != nil {
+		return domain.ErrIndexerCursorGetFailed(err)
+	}
+
+	from := s.config.StartBlock
+
+	if cursor.BlockHash != "" {
+		hash, err := s.source.BlockHash(ctx, cursor.BlockNumber)
+		if err != nil {
+			return domain.ErrTransferLogsQueryFailed(err)
+		}
+
+		if hash.Hex() != cursor.BlockHash {
+			if cursor, err = s.rollback(ctx, cursor); err != nil {
+				return err
+			}
+		}
+
+		from = cursor.BlockNumber + 1
+	}
+
+	head, err := s.source.LatestBlock(ctx)
+	if err != nil {
+		return domain.ErrTransferLogsQueryFailed(err)
+	}
+
+	contracts := indexer.Addresses(s.config.Contracts)
+
+	for from <= head {
+		to := from + s.config.BatchSize - 1
+		if to > head {
+			to = head
+		}
+
+		// the hash is read before the logs, so a reorg happening in between is detected on the next run
+		hash, err := s.source.BlockHash(ctx, to)
+		if err != nil {
+			return domain.ErrTransferLogsQueryFailed(err)
+		}
+
+		logs, err := s.source.Logs(ctx, contracts, from, to)
+		if err != nil {
+			return domain.ErrTransferLogsQueryFailed(err)
+		}
+
+		transfers := indexer.DecodeTransfers(s.config.ChainID, logs)
+
+		// clear before moving the cursor so a failure is retried by the next run
+		if err = s.clearDefaultCharacters(ctx, transfers); err != nil {
+			return err
+		}
+
+		cursor = domain.IndexerCursor{
+			Name:        s.config.Name,
+			ChainID:     s.config.ChainID,
+			BlockNumber: to,
+			BlockHash:   hash.Hex(),
+		}
+
+		if err = s.ownershipStore.Apply(ctx, transfers, cursor); err != nil {
+			return domain.ErrOwnershipApplyFailed(err)
+		}
+
+		s.logger.Infow("indexed transfers", "from", from, "to", to, "transfers", len(transfers))
+
+		from = to + 1
+	}
+
+	return nil
+}
+
+// rollback returns to the confirmed depth below the cursor, the transfers after it are ingested again
+func (s *indexerService) rollback(ctx context.Context, cursor domain.IndexerCursor) (domain.IndexerCursor, error) {
+	n := s.config.StartBlock
+	if cursor.BlockNumber > n+s.config.Confirmations {
+		n = cursor.BlockNumber - s.config.Confirmations
+	}
+
+	hash, err := s.source.BlockHash(ctx, n)
+	if err != nil {
+		return cursor, domain.ErrTransferLogsQueryFailed(err)
+	}
+
+	confirmed := domain.IndexerCursor{
+		Name:        s.config.Name,
+		ChainID:     s.config.ChainID,
+		BlockNumber: n,
+		BlockHash:   hash.Hex(),
+	}
+
+	if err = s.ownershipStore.Rollback(ctx, confirmed, s.config.Contracts); err != nil {
+		return cursor, domain.ErrOwnershipRollbackFailed(err)
+	}
+
+	s.logger.Warnw("reorg detected, rolled back", "from", cursor.BlockNumber, "to", confirmed.BlockNumber)
+
+	return confirmed, nil
+}
+
+// clearDefaultCharacters unsets the default character of users who no longer own it at the end of the batch
+func (s *indexerService) clearDefaultCharacters(ctx context.Context, transfers []domain.Transfer) error {
+	if s.config.CharacterContract.IsZero() {
+		return nil
+	}
+
+	owners := map[string]domain.EthereumAddress{}
+	for _, transfer := range transfers {
+		if transfer.ContractAddress == s.config.CharacterContract {
+			owners[transfer.TokenID] = transfer.ToAddress
+		}
+	}
+
+	for _, transfer := range transfers {
+		// mints have no previous owner
+		if transfer.ContractAddress != s.config.CharacterContract || transfer.FromAddress.IsZero() {
+			continue
+		}
+		if owners[transfer.TokenID] == transfer.FromAddress {
+			continue
+		}
+
+		if err := s.userService.ClearDefaultCharacter(ctx, transfer.FromAddress, transfer.TokenID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/indexer"
+	"github.com/manta-coder/golang-serverless-example/pkg/store"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func createTestOwnershipStore() store.OwnershipStore {
+	return store.NewOwnershipStore(tester.GetLogger(), tester.DB())
+}
+
+var testOwnershipStore = createTestOwnershipStore()
+
+func testHash() common.Hash {
+	return common.BytesToHash(ksuid.New().Bytes())
+}
+
+// testBlocks returns n blocks starting at a random height, so runs don't share blocks
+func testBlocks(n int) []indexer.FileBlock {
+	first := uint64(rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(1 << 40))
+
+	blocks := make([]indexer.FileBlock, n)
+	for i := range blocks {
+		blocks[i] = indexer.FileBlock{Number: hexutil.Uint64(first + uint64(i)), Hash: testHash()}
+	}
+
+	return blocks
+}
+
+// testTransferLog returns the log of contract moving tokenID from one address to another in block
+func testTransferLog(contract domain.EthereumAddress, tokenID int64, from, to domain.EthereumAddress, block indexer.FileBlock, logIndex uint) types.Log {
+	return types.Log{
+		Address: common.Address(contract),
+		Topics: []common.Hash{
+			indexer.TransferTopic,
+			common.BytesToHash(from[:]),
+			common.BytesToHash(to[:]),
+			common.BigToHash(big.NewInt(tokenID)),
+		},
+		BlockNumber: uint64(block.Number),
+		BlockHash:   block.Hash,
+		Index:       logIndex,
+		TxHash:      testHash(),
+	}
+}
+
+func TestIndexerService_Sync(t *testing.T) {
+	ctx := context.Background()
+
+	contract := domain.EthereumAddress(tester.GenerateAddress(t))
+	alice := domain.EthereumAddress(tester.GenerateAddress(t))
+	bob := domain.EthereumAddress(tester.GenerateAddress(t))
+	zero := domain.EthereumAddress{}
+
+	blocks := testBlocks(5)
+
+	source := &indexer.FileSource{}
+	source.Replace(indexer.File{
+		Blocks: blocks,
+		Logs: []types.Log{
+			testTransferLog(contract, 1, zero, alice, blocks[0], 0),
+			testTransferLog(contract, 2, zero, alice, blocks[1], 0),
+			testTransferLog(contract, 1, alice, bob, blocks[3], 3),
+		},
+	})
+
+	service := NewIndexerService(tester.GetLogger(), IndexerConfig{
+		Name:              ksuid.New().String(),
+		ChainID:           domain.ChainIDMainnet,
+		Contracts:         []domain.EthereumAddress{contract},
+		CharacterContract: contract,
+		StartBlock:        uint64(blocks[0].Number),
+		BatchSize:         2,
+		Confirmations:     2,
+	}, source, testOwnershipStore, testUserService)
+
+	characterID := "1"
+	user := testUser(t)
+	user.EthereumAddress = alice
+	user.DefaultCharacterID = &characterID
+	user, err := testUserStore.Store(ctx, user)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Sync(ctx))
+
+	ownership, err := testOwnershipStore.Get(ctx, domain.ChainIDMainnet, contract, "1")
+	require.NoError(t, err)
+	assert.Equal(t, bob, ownership.OwnerAddress)
+
+	ownership, err = testOwnershipStore.Get(ctx, domain.ChainIDMainnet, contract, "2")
+	require.NoError(t, err)
+	assert.Equal(t, alice, ownership.OwnerAddress)
+
+	// alice transferred her default character away
+	foundUser, err := testUserStore.Get(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Nil(t, foundUser.DefaultCharacterID)
+
+	// syncing again is a no-op
+	require.NoError(t, service.Sync(ctx))
+
+	ownership, err = testOwnershipStore.Get(ctx, domain.ChainIDMainnet, contract, "1")
+	require.NoError(t, err)
+	assert.Equal(t, bob, ownership.OwnerAddress)
+
+	// blocks 4 and 5 are replaced, token 1 never left alice and token 2 went to bob instead
+	reorg := append([]indexer.FileBlock{}, blocks[:3]...)
+	reorg = append(reorg, indexer.FileBlock{Number: blocks[3].Number, Hash: testHash()}, indexer.FileBlock{Number: blocks[4].Number, Hash: testHash()})
+
+	source.Replace(indexer.File{
+		Blocks: reorg,
+		Logs: []types.Log{
+			testTransferLog(contract, 1, zero, alice, reorg[0], 0),
+			testTransferLog(contract, 2, zero, alice, reorg[1], 0),
+			testTransferLog(contract, 2, alice, bob, reorg[4], 1),
+		},
+	})
+	require.NoError(t, service.Sync(ctx))
+
+	ownership, err = testOwnershipStore.Get(ctx, domain.ChainIDMainnet, contract, "1")
+	require.NoError(t, err)
+	assert.Equal(t, alice, ownership.OwnerAddress)
+
+	ownership, err = testOwnershipStore.Get(ctx, domain.ChainIDMainnet, contract, "2")
+	require.NoError(t, err)
+	assert.Equal(t, bob, ownership.OwnerAddress)
+}
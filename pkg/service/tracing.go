@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/auth"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/tracing"
+	"time"
+)
+
+// tracedUserService records a span for every call to the user service
+type tracedUserService struct {
+	next UserService
+}
+
+// NewTracedUserService traces the calls to next
+func NewTracedUserService(next UserService) UserService {
+	return &tracedUserService{next}
+}
+
+func (s *tracedUserService) Get(ctx context.Context, userID string) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Get")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Get(ctx, userID)
+}
+
+func (s *tracedUserService) FindByEthereumAddress(ctx context.Context, ethereumAddress domain.EthereumAddress) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindByEthereumAddress")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.FindByEthereumAddress(ctx, ethereumAddress)
+}
+
+func (s *tracedUserService) Find(ctx context.Context, identifier string) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Find")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Find(ctx, identifier)
+}
+
+func (s *tracedUserService) ResolveAddress(ctx context.Context, identifier string) (address domain.EthereumAddress, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ResolveAddress")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.ResolveAddress(ctx, identifier)
+}
+
+func (s *tracedUserService) RefreshEns(ctx context.Context, user domain.User) (result domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RefreshEns")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.RefreshEns(ctx, user)
+}
+
+func (s *tracedUserService) RefreshStaleEns(ctx context.Context, maxAge time.Duration, limit uint64) (refreshed int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RefreshStaleEns")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.RefreshStaleEns(ctx, maxAge, limit)
+}
+
+func (s *tracedUserService) Store(ctx context.Context, input domain.UserStoreInput) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Store")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Store(ctx, input)
+}
+
+func (s *tracedUserService) Update(ctx context.Context, input domain.UserUpdateInput) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Update")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Update(ctx, input)
+}
+
+func (s *tracedUserService) UpdateDefaultCharacter(ctx context.Context, userID string, characterID string) (user domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateDefaultCharacter")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.UpdateDefaultCharacter(ctx, userID, characterID)
+}
+
+func (s *tracedUserService) ClearDefaultCharacter(ctx context.Context, ethereumAddress domain.EthereumAddress, characterID string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ClearDefaultCharacter")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.ClearDefaultCharacter(ctx, ethereumAddress, characterID)
+}
+
+func (s *tracedUserService) Remove(ctx context.Context, userID string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Remove")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Remove(ctx, userID)
+}
+
+// tracedAuthService records a span for every call to the auth service
+type tracedAuthService struct {
+	next AuthService
+}
+
+// NewTracedAuthService traces the calls to next
+func NewTracedAuthService(next AuthService) AuthService {
+	return &tracedAuthService{next}
+}
+
+func (s *tracedAuthService) Challenge(ctx context.Context, input auth.ChallengeInput) (output auth.ChallengeOutput, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Challenge")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Challenge(ctx, input)
+}
+
+func (s *tracedAuthService) Authorize(ctx context.Context, input auth.AuthorizeInput) (output auth.AuthorizeOutput, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Authorize")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Authorize(ctx, input)
+}
+
+func (s *tracedAuthService) AuthorizeSilently(ctx context.Context, input auth.AuthorizeSilentlyInput) (output auth.AuthorizeOutput, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.AuthorizeSilently")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.AuthorizeSilently(ctx, input)
+}
+
+// tracedIndexerService records a span for every run of the indexer
+type tracedIndexerService struct {
+	next IndexerService
+}
+
+// NewTracedIndexerService traces the calls to next
+func NewTracedIndexerService(next IndexerService) IndexerService {
+	return &tracedIndexerService{next}
+}
+
+func (s *tracedIndexerService) Sync(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "IndexerService.Sync")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Sync(ctx)
+}
@@ -1,102 +1,226 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/ens"
+	"github.com/manta-coder/golang-serverless-example/pkg/logging"
 	"github.com/manta-coder/golang-serverless-example/pkg/store"
 	"go.uber.org/zap"
+	"time"
 )
 
 type UserService interface {
-	Get(userID string) (domain.User, error)
-	FindByEthereumAddress(ethereumAddressHex string) (domain.User, error)
-	Store(input domain.UserStoreInput) (domain.User, error)
-	Update(input domain.UserUpdateInput) (domain.User, error)
-	UpdateDefaultCharacter(userID string, characterID string) (domain.User, error)
-	Remove(userID string) error
+	Get(ctx context.Context, userID string) (domain.User, error)
+	FindByEthereumAddress(ctx context.Context, ethereumAddress domain.EthereumAddress) (domain.User, error)
+	Find(ctx context.Context, identifier string) (domain.User, error)
+	ResolveAddress(ctx context.Context, identifier string) (domain.EthereumAddress, error)
+	RefreshEns(ctx context.Context, user domain.User) (domain.User, error)
+	RefreshStaleEns(ctx context.Context, maxAge time.Duration, limit uint64) (int, error)
+	Store(ctx context.Context, input domain.UserStoreInput) (domain.User, error)
+	Update(ctx context.Context, input domain.UserUpdateInput) (domain.User, error)
+	UpdateDefaultCharacter(ctx context.Context, userID string, characterID string) (domain.User, error)
+	ClearDefaultCharacter(ctx context.Context, ethereumAddress domain.EthereumAddress, characterID string) error
+	Remove(ctx context.Context, userID string) error
 }
 
 type userService struct {
 	logger    *zap.SugaredLogger
 	userStore store.UserStore
+	resolver  ens.Resolver
 }
 
-func NewUserService(logger *zap.SugaredLogger, userStore store.UserStore) UserService {
-	return &userService{logger, userStore}
+func NewUserService(logger *zap.SugaredLogger, userStore store.UserStore, resolver ens.Resolver) UserService {
+	return &userService{logger, userStore, resolver}
 }
 
-func (s *userService) Get(userID string) (domain.User, error) {
-	user, err := s.userStore.Get(userID)
+func (s *userService) Get(ctx context.Context, userID string) (domain.User, error) {
+	user, err := s.userStore.Get(ctx, userID)
 	if err != nil {
-		return domain.User{}, domain.ErrUserGetFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserGetFailed)
 	}
 
 	return user, nil
 }
 
-func (s *userService) FindByEthereumAddress(ethereumAddressHex string) (domain.User, error) {
-	user, err := s.userStore.FindByEthereumAddress(ethereumAddressHex)
+func (s *userService) FindByEthereumAddress(ctx context.Context, ethereumAddress domain.EthereumAddress) (domain.User, error) {
+	user, err := s.userStore.FindByEthereumAddress(ctx, ethereumAddress)
 	if err != nil {
-		return domain.User{}, domain.ErrUserFindByEthereumAddressFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserFindByEthereumAddressFailed)
 	}
 
 	return user, nil
 }
 
-func (s *userService) Store(input domain.UserStoreInput) (domain.User, error) {
+// Find returns the user identified by an ENS name or an ethereum address. Names are always resolved, the name stored
+// with a user may point to another address since the last refresh
+func (s *userService) Find(ctx context.Context, identifier string) (domain.User, error) {
+	address, err := s.ResolveAddress(ctx, identifier)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.FindByEthereumAddress(ctx, address)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if user.UserID == "" {
+		return domain.User{}, domain.ErrNotFound(nil)
+	}
+
+	return user, nil
+}
+
+// ResolveAddress returns the address of an ENS name or parses an ethereum address
+func (s *userService) ResolveAddress(ctx context.Context, identifier string) (domain.EthereumAddress, error) {
+	if !ens.IsName(identifier) {
+		return domain.ParseEthereumAddress(identifier)
+	}
+
+	addressHex, err := s.resolver.Resolve(ctx, identifier)
+	switch {
+	case err == nil:
+		return domain.ParseEthereumAddress(addressHex)
+	case errors.Is(err, ens.ErrNotFound):
+		return domain.EthereumAddress{}, domain.ErrEnsNameNotFound(err)
+	default:
+		return domain.EthereumAddress{}, domain.ErrEnsResolveFailed(err)
+	}
+}
+
+// RefreshEns updates the primary name and avatar of the user
+func (s *userService) RefreshEns(ctx context.Context, user domain.User) (domain.User, error) {
+	user.EnsName = nil
+	user.EnsAvatar = nil
+
+	name, err := s.resolver.ReverseResolve(ctx, user.EthereumAddress.Hex())
+	if err != nil && !errors.Is(err, ens.ErrNotFound) {
+		return domain.User{}, domain.ErrEnsResolveFailed(err)
+	}
+
+	if name != "" {
+		user.EnsName = &name
+
+		avatar, err := s.resolver.Avatar(ctx, name)
+		if err != nil && !errors.Is(err, ens.ErrNotFound) {
+			return domain.User{}, domain.ErrEnsResolveFailed(err)
+		}
+		if avatar != "" {
+			user.EnsAvatar = &avatar
+		}
+	}
+
+	result, err := s.userStore.UpdateEns(ctx, user)
+	if err != nil {
+		return domain.User{}, storeError(err, domain.ErrEnsRefreshFailed)
+	}
+
+	return result, nil
+}
+
+// RefreshStaleEns refreshes up to limit users whose ENS profile is older than maxAge and returns how many were
+// refreshed. A user failing to resolve doesn't stop the others
+func (s *userService) RefreshStaleEns(ctx context.Context, maxAge time.Duration, limit uint64) (int, error) {
+	users, err := s.userStore.FindEnsStale(ctx, time.Now().Add(-maxAge), limit)
+	if err != nil {
+		return 0, domain.ErrEnsRefreshFailed(err)
+	}
+
+	refreshed := 0
+
+	for _, user := range users {
+		if _, err = s.RefreshEns(ctx, user); err != nil {
+			logging.FromContext(ctx, s.logger).Warnw("unable to refresh ens profile", "user_id", user.UserID, "err", err)
+			continue
+		}
+
+		refreshed++
+	}
+
+	return refreshed, nil
+}
+
+func (s *userService) Store(ctx context.Context, input domain.UserStoreInput) (domain.User, error) {
 	if err := input.Validate(); err != nil {
 		return domain.User{}, err
 	}
 
-	result, err := s.userStore.Store(domain.User{
-		EthereumAddressHex: input.EthereumAddressHexInput.EthereumAddressHex,
-		Username:           input.Username,
+	result, err := s.userStore.Store(ctx, domain.User{
+		EthereumAddress: input.Address(),
+		Username:        input.Username,
 	})
 	if err != nil {
-		return domain.User{}, domain.ErrUserStoreFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserStoreFailed)
 	}
 
 	return result, nil
 }
 
-func (s *userService) Update(input domain.UserUpdateInput) (domain.User, error) {
+// Update changes the fields set in input, the others keep their value. It fails with domain.ErrPreconditionFailed
+// when input.Version is set and the user is at another version, and with domain.ErrConflict when the user changed
+// concurrently
+func (s *userService) Update(ctx context.Context, input domain.UserUpdateInput) (domain.User, error) {
 	if err := input.Validate(); err != nil {
 		return domain.User{}, err
 	}
 
-	user, err := s.userStore.Get(input.UserID)
+	user, err := s.userStore.Get(ctx, input.UserID)
 	if err != nil {
-		return domain.User{}, domain.ErrUserFindByEthereumAddressFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserGetFailed)
+	}
+
+	if input.Version != 0 && input.Version != user.Version {
+		return domain.User{}, domain.ErrPreconditionFailed(nil)
 	}
 
-	user.Username = input.Username
+	if input.Username != nil {
+		user.Username = *input.Username
+	}
+	if input.Locale != nil {
+		user.Locale = *input.Locale
+	}
 
-	result, err := s.userStore.Update(user)
+	result, err := s.userStore.Update(ctx, user)
+	if input.Version != 0 && errors.Is(err, db.ErrVersionMismatch) {
+		// the version the client saw was current when read but not anymore
+		return domain.User{}, domain.ErrPreconditionFailed(err)
+	}
 	if err != nil {
-		return domain.User{}, domain.ErrUserUpdateFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserUpdateFailed)
 	}
 
 	return result, nil
 }
 
-func (s *userService) UpdateDefaultCharacter(userID string, characterID string) (domain.User, error) {
-	user, err := s.userStore.Get(userID)
+func (s *userService) UpdateDefaultCharacter(ctx context.Context, userID string, characterID string) (domain.User, error) {
+	user, err := s.userStore.Get(ctx, userID)
 	if err != nil {
-		return domain.User{}, domain.ErrUserFindByEthereumAddressFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserGetFailed)
 	}
 
 	user.DefaultCharacterID = &characterID
 
-	result, err := s.userStore.Update(user)
+	result, err := s.userStore.Update(ctx, user)
 	if err != nil {
-		return domain.User{}, domain.ErrUserUpdateFailed(err)
+		return domain.User{}, storeError(err, domain.ErrUserUpdateFailed)
 	}
 
 	return result, nil
 }
 
-func (s *userService) Remove(userID string) error {
-	if err := s.userStore.Remove(userID); err != nil {
-		return domain.ErrUserRemoveFailed(err)
+func (s *userService) ClearDefaultCharacter(ctx context.Context, ethereumAddress domain.EthereumAddress, characterID string) error {
+	if err := s.userStore.ClearDefaultCharacter(ctx, ethereumAddress, characterID); err != nil {
+		return storeError(err, domain.ErrDefaultCharacterClearFailed)
+	}
+
+	return nil
+}
+
+func (s *userService) Remove(ctx context.Context, userID string) error {
+	if err := s.userStore.Remove(ctx, userID); err != nil {
+		return storeError(err, domain.ErrUserRemoveFailed)
 	}
 
 	return nil
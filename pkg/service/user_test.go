@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/ens"
 	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
 	"github.com/manta-coder/golang-serverless-example/pkg/store"
 	"github.com/manta-coder/golang-serverless-example/pkg/tester"
@@ -23,10 +25,10 @@ func testUser(t *testing.T) domain.User {
 	now := time.Now()
 
 	return domain.User{
-		EthereumAddressHex: tester.GenerateEthereumAddress(t),
-		Username:           helpers.Rand(20),
-		CreatedAt:          now,
-		UpdatedAt:          now,
+		EthereumAddress: domain.EthereumAddress(tester.GenerateAddress(t)),
+		Username:        helpers.Rand(20),
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 }
 
@@ -35,7 +37,7 @@ func createTestUser(t *testing.T) domain.User {
 
 	user := testUser(t)
 
-	user, err := testUserStore.Store(user)
+	user, err := testUserStore.Store(context.Background(), user)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -43,8 +45,10 @@ func createTestUser(t *testing.T) domain.User {
 	return user
 }
 
+var testResolver = ens.NewFakeResolver()
+
 func createTestUserService() UserService {
-	return NewUserService(tester.GetLogger(), testUserStore)
+	return NewUserService(tester.GetLogger(), testUserStore, testResolver)
 }
 
 var testUserService = createTestUserService()
@@ -52,52 +56,171 @@ var testUserService = createTestUserService()
 func TestUserService_Store(t *testing.T) {
 	user := testUser(t)
 
-	createdUser, err := testUserService.Store(domain.NewUserStoreInput(user.EthereumAddressHex, user.Username))
+	createdUser, err := testUserService.Store(context.Background(), domain.NewUserStoreInput(user.EthereumAddress.Hex(), user.Username))
 	require.NoError(t, err)
 
 	user.UserID = createdUser.UserID
 	tester.AssertEqual(t, user, createdUser)
+
+	_, err = testUserService.Store(context.Background(), domain.NewUserStoreInput(user.EthereumAddress.Hex(), user.Username))
+	require.Error(t, err)
+	assert.Equal(t, domain.ErrConflict(nil).Code, err.(*domain.Error).Code)
 }
 
 func TestUserService_Get(t *testing.T) {
 	user := createTestUser(t)
 
-	foundUser, err := testUserService.Get(user.UserID)
+	foundUser, err := testUserService.Get(context.Background(), user.UserID)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, user, foundUser)
+
+	// unknown users are not found instead of failing
+	_, err = testUserService.Get(context.Background(), "usr_unknown")
+	require.Error(t, err)
+	assert.Equal(t, domain.ErrNotFound(nil).Code, err.(*domain.Error).Code)
 }
 
 func TestUserService_FindByEthereumAddress(t *testing.T) {
 	user := createTestUser(t)
 
-	foundUser, err := testUserService.FindByEthereumAddress(user.EthereumAddressHex)
+	foundUser, err := testUserService.FindByEthereumAddress(context.Background(), user.EthereumAddress)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, user, foundUser)
 }
 
+func TestUserService_Find(t *testing.T) {
+	ctx := context.Background()
+	user := createTestUser(t)
+
+	foundUser, err := testUserService.Find(ctx, user.EthereumAddress.Hex())
+	require.NoError(t, err)
+	tester.AssertEqual(t, user, foundUser)
+
+	// names are resolved until the profile is refreshed
+	name := helpers.Rand(10) + ".eth"
+	testResolver.Register(name, user.EthereumAddress.Hex(), "")
+
+	foundUser, err = testUserService.Find(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, user.UserID, foundUser.UserID)
+
+	// a stored name that moved to another address finds its new holder
+	_, err = testUserService.RefreshEns(ctx, user)
+	require.NoError(t, err)
+
+	other := createTestUser(t)
+	testResolver.Register(name, other.EthereumAddress.Hex(), "")
+
+	foundUser, err = testUserService.Find(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, other.UserID, foundUser.UserID)
+
+	_, err = testUserService.Find(ctx, helpers.Rand(10)+".eth")
+	assert.Error(t, err)
+
+	_, err = testUserService.Find(ctx, tester.GenerateEthereumAddress(t))
+	assert.Error(t, err)
+}
+
+func TestUserService_RefreshEns(t *testing.T) {
+	ctx := context.Background()
+	user := createTestUser(t)
+
+	name := helpers.Rand(10) + ".eth"
+	testResolver.Register(name, user.EthereumAddress.Hex(), "https://example.com/avatar.png")
+
+	refreshed, err := testUserService.RefreshStaleEns(ctx, time.Hour, 1000)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, refreshed, 1)
+
+	foundUser, err := testUserStore.Get(ctx, user.UserID)
+	require.NoError(t, err)
+	require.NotNil(t, foundUser.EnsName)
+	assert.Equal(t, name, *foundUser.EnsName)
+	require.NotNil(t, foundUser.EnsAvatar)
+	assert.Equal(t, "https://example.com/avatar.png", *foundUser.EnsAvatar)
+
+	// the name is cleared once it no longer points to the user
+	testResolver.Unregister(name)
+
+	foundUser, err = testUserService.RefreshEns(ctx, foundUser)
+	require.NoError(t, err)
+	assert.Nil(t, foundUser.EnsName)
+	assert.Nil(t, foundUser.EnsAvatar)
+
+	// a name moves to its new holder, the previous one is refreshed again first
+	name = helpers.Rand(10) + ".eth"
+	testResolver.Register(name, user.EthereumAddress.Hex(), "")
+	_, err = testUserService.RefreshEns(ctx, foundUser)
+	require.NoError(t, err)
+
+	other := createTestUser(t)
+	testResolver.Register(name, other.EthereumAddress.Hex(), "")
+
+	other, err = testUserService.RefreshEns(ctx, other)
+	require.NoError(t, err)
+	require.NotNil(t, other.EnsName)
+	assert.Equal(t, name, *other.EnsName)
+
+	foundUser, err = testUserStore.Get(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Nil(t, foundUser.EnsName)
+	assert.Nil(t, foundUser.EnsRefreshedAt)
+}
+
 func TestUserService_Update(t *testing.T) {
 	user := createTestUser(t)
 
 	updateUser := testUser(t)
 	updateUser.UserID = user.UserID
 
-	_, err := testUserService.Update(domain.NewUserUpdateInput(updateUser.UserID, updateUser.Username))
+	_, err := testUserService.Update(context.Background(), domain.NewUserUpdateInput(updateUser.UserID, 0).WithUsername(updateUser.Username))
 	require.NoError(t, err)
 
-	foundUser, err := testUserStore.Get(user.UserID)
+	foundUser, err := testUserStore.Get(context.Background(), user.UserID)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, updateUser, foundUser)
+
+	// the expected version must be the current one
+	_, err = testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, user.Version).WithUsername("renamed"))
+	require.Error(t, err)
+	assert.Equal(t, domain.ErrPreconditionFailed(nil).Code, err.(*domain.Error).Code)
+
+	updatedUser, err := testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, foundUser.Version).WithUsername("renamed").WithLocale("ja"))
+	require.NoError(t, err)
+	assert.Equal(t, foundUser.Version+1, updatedUser.Version)
+	assert.Equal(t, "ja", updatedUser.Locale)
+
+	// only supported locales can be preferred
+	_, err = testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, 0).WithLocale("de"))
+	require.Error(t, err)
+
+	// the fields left out keep their value
+	updatedUser, err = testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, 0).WithLocale(" en "))
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", updatedUser.Username)
+	// stored as validated
+	assert.Equal(t, "en", updatedUser.Locale)
+
+	updatedUser, err = testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, 0).WithUsername("  trimmed "))
+	require.NoError(t, err)
+	assert.Equal(t, "trimmed", updatedUser.Username)
+	assert.Equal(t, "en", updatedUser.Locale)
+
+	// a username can't be blanked
+	_, err = testUserService.Update(context.Background(), domain.NewUserUpdateInput(user.UserID, 0).WithUsername(""))
+	require.Error(t, err)
 }
 
 func TestUserService_Remove(t *testing.T) {
 	user := createTestUser(t)
 
-	err := testUserService.Remove(user.UserID)
+	err := testUserService.Remove(context.Background(), user.UserID)
 	require.NoError(t, err)
 
-	user, err = testUserService.Get(user.UserID)
+	user, err = testUserService.Get(context.Background(), user.UserID)
 	assert.Equal(t, user.UserID, "")
 }
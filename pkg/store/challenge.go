@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
 	"github.com/manta-coder/golang-serverless-example/pkg/db"
@@ -11,9 +12,9 @@ import (
 )
 
 type ChallengeStore interface {
-	Get(ethereumAddress string) (domain.Challenge, error)
-	Store(challenge domain.Challenge) (domain.Challenge, error)
-	Remove(ethereumAddress string) error
+	Get(ctx context.Context, ethereumAddress domain.EthereumAddress, chainID domain.ChainID) (domain.Challenge, error)
+	Store(ctx context.Context, challenge domain.Challenge) (domain.Challenge, error)
+	Remove(ctx context.Context, ethereumAddress domain.EthereumAddress, chainID domain.ChainID) error
 }
 
 type challengeStore struct {
@@ -25,23 +26,23 @@ func NewChallengeStore(logger *zap.SugaredLogger, db *sqlx.DB) ChallengeStore {
 	return &challengeStore{logger, db}
 }
 
-func (s *challengeStore) Get(ethereumAddressHex string) (domain.Challenge, error) {
+func (s *challengeStore) Get(ctx context.Context, ethereumAddress domain.EthereumAddress, chainID domain.ChainID) (domain.Challenge, error) {
 	var result domain.Challenge
 
 	query, args, _ := sq.Select(challengesColumns...).
 		From(challengesTable).
-		Where(squirrel.Eq{"ethereum_address": ethereumAddressHex}).
+		Where(squirrel.Eq{"ethereum_address": ethereumAddress, "chain_id": chainID}).
 		OrderBy("created_at DESC").
 		ToSql()
 
-	if err := s.db.Get(&result, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &result, query, args...); err != nil {
 		return result, db.QueryExecuteError(err, query, args)
 	}
 
 	return result, nil
 }
 
-func (s *challengeStore) Store(challenge domain.Challenge) (domain.Challenge, error) {
+func (s *challengeStore) Store(ctx context.Context, challenge domain.Challenge) (domain.Challenge, error) {
 	now := time.Now()
 
 	challenge.ChallengeID = "chl_" + ksuid.New().String()
@@ -51,25 +52,26 @@ func (s *challengeStore) Store(challenge domain.Challenge) (domain.Challenge, er
 		Columns(challengesColumns...).
 		Values(
 			challenge.ChallengeID,
-			challenge.EthereumAddressHex,
+			challenge.EthereumAddress,
+			challenge.ChainID,
 			challenge.Challenge,
 			challenge.CreatedAt,
 		).
 		ToSql()
 
-	if _, err := s.db.Exec(query, args...); err != nil {
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
 		return challenge, db.QueryExecuteError(err, query, args)
 	}
 
 	return challenge, nil
 }
 
-func (s *challengeStore) Remove(ethereumAddress string) error {
+func (s *challengeStore) Remove(ctx context.Context, ethereumAddress domain.EthereumAddress, chainID domain.ChainID) error {
 	query, args, _ := sq.Delete(challengesTable).
-		Where(squirrel.Eq{"ethereum_address": ethereumAddress}).
+		Where(squirrel.Eq{"ethereum_address": ethereumAddress, "chain_id": chainID}).
 		ToSql()
 
-	if _, err := s.db.Exec(query, args...); err != nil {
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
 		return db.QueryExecuteError(err, query, args)
 	}
 
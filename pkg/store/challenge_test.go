@@ -1,11 +1,11 @@
 package store
 
 import (
+	"context"
 	"github.com/manta-coder/golang-serverless-example/pkg/auth"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/helpers"
 	"github.com/manta-coder/golang-serverless-example/pkg/tester"
-	"github.com/segmentio/ksuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -21,8 +21,9 @@ func testChallenge(t *testing.T) domain.Challenge {
 	t.Helper()
 
 	return domain.Challenge{
-		EthereumAddressHex: tester.GenerateEthereumAddress(t),
-		Challenge:          helpers.Rand(auth.ChallengeStringLength),
+		EthereumAddress: domain.EthereumAddress(tester.GenerateAddress(t)),
+		ChainID:         domain.ChainIDMainnet,
+		Challenge:       helpers.Rand(auth.ChallengeStringLength),
 	}
 }
 
@@ -31,7 +32,7 @@ func createTestChallenge(t *testing.T) domain.Challenge {
 
 	challenge := testChallenge(t)
 
-	challenge, err := testChallengeStore.Store(challenge)
+	challenge, err := testChallengeStore.Store(context.Background(), challenge)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -42,7 +43,7 @@ func createTestChallenge(t *testing.T) domain.Challenge {
 func TestChallengeStore_Store(t *testing.T) {
 	challenge := testChallenge(t)
 
-	createdChallenge, err := testChallengeStore.Store(challenge)
+	createdChallenge, err := testChallengeStore.Store(context.Background(), challenge)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, challenge, createdChallenge)
@@ -51,23 +52,27 @@ func TestChallengeStore_Store(t *testing.T) {
 func TestChallengeStore_Get(t *testing.T) {
 	challenge := createTestChallenge(t)
 
-	foundChallenge, err := testChallengeStore.Get(challenge.EthereumAddressHex)
+	foundChallenge, err := testChallengeStore.Get(context.Background(), challenge.EthereumAddress, challenge.ChainID)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, challenge, foundChallenge)
 
+	// challenges are scoped to a chain
+	_, err = testChallengeStore.Get(context.Background(), challenge.EthereumAddress, challenge.ChainID+1)
+	assert.Error(t, err)
+
 	// should error if no user matches ID
-	_, err = testChallengeStore.Get(ksuid.New().String())
+	_, err = testChallengeStore.Get(context.Background(), domain.EthereumAddress(tester.GenerateAddress(t)), domain.ChainIDMainnet)
 	assert.Error(t, err)
 }
 
 func TestChallengeStore_Remove(t *testing.T) {
 	challenge := createTestChallenge(t)
 
-	err := testChallengeStore.Remove(challenge.EthereumAddressHex)
+	err := testChallengeStore.Remove(context.Background(), challenge.EthereumAddress, challenge.ChainID)
 	require.NoError(t, err)
 
 	// should error if no user matches ID
-	_, err = testChallengeStore.Get(challenge.EthereumAddressHex)
+	_, err = testChallengeStore.Get(context.Background(), challenge.EthereumAddress, challenge.ChainID)
 	assert.Error(t, err)
 }
@@ -10,20 +10,26 @@ import (
 var sq = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 
 const (
-	usersTable         = "users"
-	challengesTable    = "challenges"
-	clansTable         = "clans"
-	charactersTable    = "characters"
-	notificationsTable = "notifications"
-	SquadsTable        = "squads"
+	usersTable          = "users"
+	challengesTable     = "challenges"
+	clansTable          = "clans"
+	charactersTable     = "characters"
+	notificationsTable  = "notifications"
+	SquadsTable         = "squads"
+	transfersTable      = "nft_transfers"
+	ownershipsTable     = "nft_ownerships"
+	indexerCursorsTable = "indexer_cursors"
 )
 
 // used to facilitate select all fields without using wildcard (*)
 var (
-	usersColumns         = db.GetDBColumns(domain.User{})
-	challengesColumns    = db.GetDBColumns(domain.Challenge{})
-	clansColumns         = db.GetDBColumns(domain.Clan{})
-	charactersColumns    = db.GetDBColumns(domain.Character{})
-	notificationsColumns = db.GetDBColumns(domain.Notification{})
-	SquadsColumns        = db.GetDBColumns(domain.Squad{})
+	usersColumns          = db.GetDBColumns(domain.User{})
+	challengesColumns     = db.GetDBColumns(domain.Challenge{})
+	clansColumns          = db.GetDBColumns(domain.Clan{})
+	charactersColumns     = db.GetDBColumns(domain.Character{})
+	notificationsColumns  = db.GetDBColumns(domain.Notification{})
+	SquadsColumns         = db.GetDBColumns(domain.Squad{})
+	transfersColumns      = db.GetDBColumns(domain.Transfer{})
+	ownershipsColumns     = db.GetDBColumns(domain.Ownership{})
+	indexerCursorsColumns = db.GetDBColumns(domain.IndexerCursor{})
 )
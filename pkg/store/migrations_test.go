@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCheckSchema runs the readiness check against the database migrated by the migration script, the way
+// deployments migrate, so the script and the migrations of the repository can't drift apart
+func TestCheckSchema(t *testing.T) {
+	ctx := context.Background()
+
+	version, dirty, err := db.SchemaVersion(ctx, tester.DB())
+	require.NoError(t, err)
+	assert.False(t, dirty)
+
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	var latest uint64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+
+		migration, err := strconv.ParseUint(prefix, 10, 64)
+		require.NoError(t, err, file)
+
+		if migration > latest {
+			latest = migration
+		}
+	}
+
+	// a deployment setting DB_SCHEMA_VERSION to the newest migration of the repository is ready
+	assert.NoError(t, db.CheckSchema(ctx, tester.DB(), latest))
+	assert.NoError(t, db.CheckSchema(ctx, tester.DB(), version))
+
+	err = db.CheckSchema(ctx, tester.DB(), version+1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is behind")
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"go.uber.org/zap"
+	"time"
+)
+
+type OwnershipStore interface {
+	Get(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, tokenID string) (domain.Ownership, error)
+	FindByOwner(ctx context.Context, chainID domain.ChainID, ownerAddress domain.EthereumAddress) ([]domain.Ownership, error)
+	CountByOwner(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error)
+	GetCursor(ctx context.Context, name string) (domain.IndexerCursor, error)
+	Apply(ctx context.Context, transfers []domain.Transfer, cursor domain.IndexerCursor) error
+	Rollback(ctx context.Context, cursor domain.IndexerCursor, contracts []domain.EthereumAddress) error
+}
+
+type ownershipStore struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewOwnershipStore(logger *zap.SugaredLogger, db *sqlx.DB) OwnershipStore {
+	return &ownershipStore{logger, db}
+}
+
+func (s *ownershipStore) Get(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, tokenID string) (domain.Ownership, error) {
+	var result domain.Ownership
+
+	query, args, _ := sq.Select(ownershipsColumns...).
+		From(ownershipsTable).
+		Where(squirrel.Eq{"chain_id": chainID, "contract_address": contractAddress, "token_id": tokenID}).
+		ToSql()
+
+	if err := s.db.GetContext(ctx, &result, query, args...); err != nil {
+		return result, db.QueryExecuteError(err, query, args)
+	}
+
+	return result, nil
+}
+
+func (s *ownershipStore) FindByOwner(ctx context.Context, chainID domain.ChainID, ownerAddress domain.EthereumAddress) ([]domain.Ownership, error) {
+	var result []domain.Ownership
+
+	query, args, _ := sq.Select(ownershipsColumns...).
+		From(ownershipsTable).
+		Where(squirrel.Eq{"chain_id": chainID, "owner_address": ownerAddress}).
+		OrderBy("contract_address", "token_id").
+		ToSql()
+
+	if err := s.db.SelectContext(ctx, &result, query, args...); err != nil {
+		return result, db.QueryExecuteError(err, query, args)
+	}
+
+	return result, nil
+}
+
+func (s *ownershipStore) CountByOwner(ctx context.Context, chainID domain.ChainID, contractAddress domain.EthereumAddress, ownerAddress domain.EthereumAddress) (uint64, error) {
+	var result uint64
+
+	query, args, _ := sq.Select("COUNT(*)").
+		From(ownershipsTable).
+		Where(squirrel.Eq{"chain_id": chainID, "contract_address": contractAddress, "owner_address": ownerAddress}).
+		ToSql()
+
+	if err := s.db.GetContext(ctx, &result, query, args...); err != nil {
+		return result, db.QueryExecuteError(err, query, args)
+	}
+
+	return result, nil
+}
+
+// GetCursor returns the cursor of the indexer or an empty cursor if it never ran
+func (s *ownershipStore) GetCursor(ctx context.Context, name string) (domain.IndexerCursor, error) {
+	var result domain.IndexerCursor
+
+	query, args, _ := sq.Select(indexerCursorsColumns...).
+		From(indexerCursorsTable).
+		Where(squirrel.Eq{"name": name}).
+		ToSql()
+
+	err := s.db.GetContext(ctx, &result, query, args...)
+	switch err {
+	case nil:
+		return result, nil
+	case sql.ErrNoRows:
+		return domain.IndexerCursor{Name: name}, nil
+	default:
+		return result, db.QueryExecuteError(err, query, args)
+	}
+}
+
+// Apply records transfers, moves ownerships and advances the cursor in a single transaction.
+// Transfers are identified by contract, block number and log index, so applying the same batch twice is a no-op
+// and an ownership is only replaced by a more recent transfer. The transaction is retried on deadlocks and lost connections.
+func (s *ownershipStore) Apply(ctx context.Context, transfers []domain.Transfer, cursor domain.IndexerCursor) error {
+	now := time.Now()
+
+	return db.RetryTransaction(ctx, s.db, db.DefaultBackoff, func(tx *sqlx.Tx) error {
+		for _, transfer := range transfers {
+			transfer.CreatedAt = now
+
+			query, args, _ := sq.Insert(transfersTable).
+				Columns(transfersColumns...).
+				Values(
+					transfer.ChainID,
+					transfer.ContractAddress,
+					transfer.TokenID,
+					transfer.FromAddress,
+					transfer.ToAddress,
+					transfer.BlockNumber,
+					transfer.BlockHash,
+					transfer.LogIndex,
+					transfer.TransactionHash,
+					transfer.CreatedAt,
+				).
+				Suffix("ON CONFLICT (chain_id, contract_address, block_number, log_index) DO NOTHING").
+				ToSql()
+
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return db.QueryExecuteError(err, query, args)
+			}
+
+			ownership := domain.NewOwnershipFromTransfer(transfer)
+			ownership.UpdatedAt = now
+
+			query, args, _ = sq.Insert(ownershipsTable).
+				Columns(ownershipsColumns...).
+				Values(
+					ownership.ChainID,
+					ownership.ContractAddress,
+					ownership.TokenID,
+					ownership.OwnerAddress,
+					ownership.BlockNumber,
+					ownership.LogIndex,
+					ownership.UpdatedAt,
+				).
+				Suffix(`ON CONFLICT (chain_id, contract_address, token_id) DO UPDATE SET
+					owner_address = EXCLUDED.owner_address,
+					block_number = EXCLUDED.block_number,
+					log_index = EXCLUDED.log_index,
+					updated_at = EXCLUDED.updated_at
+				WHERE (` + ownershipsTable + `.block_number, ` + ownershipsTable + `.log_index) < (EXCLUDED.block_number, EXCLUDED.log_index)`).
+				ToSql()
+
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return db.QueryExecuteError(err, query, args)
+			}
+		}
+
+		return storeCursor(ctx, tx, cursor, now)
+	})
+}
+
+// Rollback forgets the transfers of contracts on the cursor chain after the cursor block and restores their ownerships
+// from the remaining transfers. Other contracts may be indexed by another indexer and are left as is
+func (s *ownershipStore) Rollback(ctx context.Context, cursor domain.IndexerCursor, contracts []domain.EthereumAddress) error {
+	now := time.Now()
+
+	return db.RetryTransaction(ctx, s.db, db.DefaultBackoff, func(tx *sqlx.Tx) error {
+		query, args, _ := sq.Delete(transfersTable).
+			Where(squirrel.Eq{"chain_id": cursor.ChainID, "contract_address": contracts}).
+			Where(squirrel.Gt{"block_number": cursor.BlockNumber}).
+			ToSql()
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return db.QueryExecuteError(err, query, args)
+		}
+
+		query, args, _ = sq.Delete(ownershipsTable).
+			Where(squirrel.Eq{"chain_id": cursor.ChainID, "contract_address": contracts}).
+			Where(squirrel.Gt{"block_number": cursor.BlockNumber}).
+			ToSql()
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return db.QueryExecuteError(err, query, args)
+		}
+
+		// the latest remaining transfer of each token without an ownership is its owner at the cursor block.
+		// the sub-select keeps ? placeholders, they are converted once for the whole statement
+		latest := squirrel.Select("DISTINCT ON (t.contract_address, t.token_id) t.chain_id", "t.contract_address", "t.token_id", "t.to_address", "t.block_number", "t.log_index").
+			Column("?::timestamptz", now).
+			From(transfersTable+" t").
+			Where(squirrel.Eq{"t.chain_id": cursor.ChainID, "t.contract_address": contracts}).
+			Where("NOT EXISTS (SELECT 1 FROM "+ownershipsTable+" o WHERE o.chain_id = t.chain_id AND o.contract_address = t.contract_address AND o.token_id = t.token_id)").
+			OrderBy("t.contract_address", "t.token_id", "t.block_number DESC", "t.log_index DESC")
+
+		query, args, _ = sq.Insert(ownershipsTable).
+			Columns(ownershipsColumns...).
+			Select(latest).
+			ToSql()
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return db.QueryExecuteError(err, query, args)
+		}
+
+		return storeCursor(ctx, tx, cursor, now)
+	})
+}
+
+func storeCursor(ctx context.Context, tx *sqlx.Tx, cursor domain.IndexerCursor, now time.Time) error {
+	cursor.UpdatedAt = now
+
+	query, args, _ := sq.Insert(indexerCursorsTable).
+		Columns(indexerCursorsColumns...).
+		Values(
+			cursor.Name,
+			cursor.ChainID,
+			cursor.BlockNumber,
+			cursor.BlockHash,
+			cursor.UpdatedAt,
+		).
+		Suffix(`ON CONFLICT (name) DO UPDATE SET
+			chain_id = EXCLUDED.chain_id,
+			block_number = EXCLUDED.block_number,
+			block_hash = EXCLUDED.block_hash,
+			updated_at = EXCLUDED.updated_at`).
+		ToSql()
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return db.QueryExecuteError(err, query, args)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/domain"
+	"github.com/manta-coder/golang-serverless-example/pkg/tester"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func createTestOwnershipStore() OwnershipStore {
+	return NewOwnershipStore(tester.GetLogger(), tester.DB())
+}
+
+var testOwnershipStore = createTestOwnershipStore()
+
+func testTransfer(t *testing.T, contract domain.EthereumAddress, tokenID string, from, to domain.EthereumAddress, blockNumber uint64, logIndex uint) domain.Transfer {
+	t.Helper()
+
+	return domain.Transfer{
+		ChainID:         domain.ChainIDMainnet,
+		ContractAddress: contract,
+		TokenID:         tokenID,
+		FromAddress:     from,
+		ToAddress:       to,
+		BlockNumber:     blockNumber,
+		BlockHash:       ksuid.New().String(),
+		LogIndex:        logIndex,
+		TransactionHash: ksuid.New().String(),
+	}
+}
+
+func testEthereumAddress(t *testing.T) domain.EthereumAddress {
+	t.Helper()
+
+	return domain.EthereumAddress(tester.GenerateAddress(t))
+}
+
+func TestOwnershipStore_Apply(t *testing.T) {
+	contract := testEthereumAddress(t)
+	alice := testEthereumAddress(t)
+	bob := testEthereumAddress(t)
+	cursor := domain.IndexerCursor{Name: ksuid.New().String(), ChainID: domain.ChainIDMainnet, BlockNumber: 10, BlockHash: "0x10"}
+
+	transfers := []domain.Transfer{
+		testTransfer(t, contract, "1", alice, bob, 10, 1),
+		// older transfer applied after a newer one must not move the ownership back
+		testTransfer(t, contract, "1", testEthereumAddress(t), alice, 9, 0),
+	}
+
+	err := testOwnershipStore.Apply(context.Background(), transfers, cursor)
+	require.NoError(t, err)
+
+	// applying the same batch twice is a no-op
+	err = testOwnershipStore.Apply(context.Background(), transfers, cursor)
+	require.NoError(t, err)
+
+	ownership, err := testOwnershipStore.Get(context.Background(), domain.ChainIDMainnet, contract, "1")
+	require.NoError(t, err)
+	assert.Equal(t, bob, ownership.OwnerAddress)
+	assert.Equal(t, uint64(10), ownership.BlockNumber)
+
+	ownerships, err := testOwnershipStore.FindByOwner(context.Background(), domain.ChainIDMainnet, bob)
+	require.NoError(t, err)
+	assert.Len(t, ownerships, 1)
+
+	count, err := testOwnershipStore.CountByOwner(context.Background(), domain.ChainIDMainnet, contract, bob)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	// the same contract address on another chain is a different contract
+	count, err = testOwnershipStore.CountByOwner(context.Background(), 42161, contract, bob)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	foundCursor, err := testOwnershipStore.GetCursor(context.Background(), cursor.Name)
+	require.NoError(t, err)
+	tester.AssertEqual(t, cursor, foundCursor)
+}
+
+func TestOwnershipStore_Rollback(t *testing.T) {
+	contract := testEthereumAddress(t)
+	alice := testEthereumAddress(t)
+	bob := testEthereumAddress(t)
+	cursor := domain.IndexerCursor{Name: ksuid.New().String(), ChainID: domain.ChainIDMainnet, BlockNumber: 100020, BlockHash: "0x20"}
+
+	err := testOwnershipStore.Apply(context.Background(), []domain.Transfer{
+		testTransfer(t, contract, "1", alice, bob, 100020, 0),
+	}, cursor)
+	require.NoError(t, err)
+
+	// an earlier transfer survives the rollback and gives the token back to alice
+	err = testOwnershipStore.Apply(context.Background(), []domain.Transfer{
+		testTransfer(t, contract, "1", testEthereumAddress(t), alice, 100010, 0),
+	}, cursor)
+	require.NoError(t, err)
+
+	// another contract emitting at the same position is indexed on its own
+	other := testEthereumAddress(t)
+	err = testOwnershipStore.Apply(context.Background(), []domain.Transfer{
+		testTransfer(t, other, "1", alice, bob, 100020, 0),
+	}, domain.IndexerCursor{Name: ksuid.New().String(), ChainID: domain.ChainIDMainnet, BlockNumber: 100020, BlockHash: "0x20"})
+	require.NoError(t, err)
+
+	confirmed := domain.IndexerCursor{Name: cursor.Name, ChainID: domain.ChainIDMainnet, BlockNumber: 100015, BlockHash: "0x15"}
+
+	err = testOwnershipStore.Rollback(context.Background(), confirmed, []domain.EthereumAddress{contract})
+	require.NoError(t, err)
+
+	ownership, err := testOwnershipStore.Get(context.Background(), domain.ChainIDMainnet, contract, "1")
+	require.NoError(t, err)
+	assert.Equal(t, alice, ownership.OwnerAddress)
+
+	// contracts of other indexers are left as is
+	ownership, err = testOwnershipStore.Get(context.Background(), domain.ChainIDMainnet, other, "1")
+	require.NoError(t, err)
+	assert.Equal(t, bob, ownership.OwnerAddress)
+
+	foundCursor, err := testOwnershipStore.GetCursor(context.Background(), cursor.Name)
+	require.NoError(t, err)
+	tester.AssertEqual(t, confirmed, foundCursor)
+}
+
+func TestOwnershipStore_GetCursor(t *testing.T) {
+	name := ksuid.New().String()
+
+	// an indexer that never ran starts with an empty cursor
+	cursor, err := testOwnershipStore.GetCursor(context.Background(), name)
+	require.NoError(t, err)
+	assert.Equal(t, domain.IndexerCursor{Name: name}, cursor)
+}
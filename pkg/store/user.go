@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
@@ -12,11 +13,14 @@ import (
 )
 
 type UserStore interface {
-	Get(userID string) (domain.User, error)
-	FindByEthereumAddress(ethereumAddressHex string) (domain.User, error)
-	Store(user domain.User) (domain.User, error)
-	Update(user domain.User) (domain.User, error)
-	Remove(userID string) error
+	Get(ctx context.Context, userID string) (domain.User, error)
+	FindByEthereumAddress(ctx context.Context, ethereumAddress domain.EthereumAddress) (domain.User, error)
+	FindEnsStale(ctx context.Context, before time.Time, limit uint64) ([]domain.User, error)
+	Store(ctx context.Context, user domain.User) (domain.User, error)
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	UpdateEns(ctx context.Context, user domain.User) (domain.User, error)
+	ClearDefaultCharacter(ctx context.Context, ethereumAddress domain.EthereumAddress, characterID string) error
+	Remove(ctx context.Context, userID string) error
 }
 
 type userStore struct {
@@ -28,7 +32,7 @@ func NewUserStore(logger *zap.SugaredLogger, db *sqlx.DB) UserStore {
 	return &userStore{logger, db}
 }
 
-func (s *userStore) Get(userID string) (domain.User, error) {
+func (s *userStore) Get(ctx context.Context, userID string) (domain.User, error) {
 	var result domain.User
 
 	query, args, _ := sq.Select(usersColumns...).
@@ -36,22 +40,22 @@ func (s *userStore) Get(userID string) (domain.User, error) {
 		Where(squirrel.Eq{"user_id": userID}).
 		ToSql()
 
-	if err := s.db.Get(&result, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &result, query, args...); err != nil {
 		return result, db.QueryExecuteError(err, query, args)
 	}
 
 	return result, nil
 }
 
-func (s *userStore) FindByEthereumAddress(ethereumAddressHex string) (domain.User, error) {
+func (s *userStore) FindByEthereumAddress(ctx context.Context, ethereumAddress domain.EthereumAddress) (domain.User, error) {
 	var result domain.User
 
 	query, args, _ := sq.Select(usersColumns...).
 		From(usersTable).
-		Where(squirrel.Eq{"ethereum_address": ethereumAddressHex}).
+		Where(squirrel.Eq{"ethereum_address": ethereumAddress}).
 		ToSql()
 
-	err := s.db.Get(&result, query, args...)
+	err := s.db.GetContext(ctx, &result, query, args...)
 	switch err {
 	case nil:
 		return result, nil
@@ -62,10 +66,32 @@ func (s *userStore) FindByEthereumAddress(ethereumAddressHex string) (domain.Use
 	}
 }
 
-func (s *userStore) Store(user domain.User) (domain.User, error) {
+// FindEnsStale returns the users whose ENS profile was never refreshed or not since before, least recent first
+func (s *userStore) FindEnsStale(ctx context.Context, before time.Time, limit uint64) ([]domain.User, error) {
+	var result []domain.User
+
+	query, args, _ := sq.Select(usersColumns...).
+		From(usersTable).
+		Where(squirrel.Or{
+			squirrel.Eq{"ens_refreshed_at": nil},
+			squirrel.Lt{"ens_refreshed_at": before},
+		}).
+		OrderBy("ens_refreshed_at ASC NULLS FIRST").
+		Limit(limit).
+		ToSql()
+
+	if err := s.db.SelectContext(ctx, &result, query, args...); err != nil {
+		return result, db.QueryExecuteError(err, query, args)
+	}
+
+	return result, nil
+}
+
+func (s *userStore) Store(ctx context.Context, user domain.User) (domain.User, error) {
 	now := time.Now()
 
 	user.UserID = "usr_" + ksuid.New().String()
+	user.Version = 1
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
@@ -73,22 +99,29 @@ func (s *userStore) Store(user domain.User) (domain.User, error) {
 		Columns(usersColumns...).
 		Values(
 			user.UserID,
-			user.EthereumAddressHex,
+			user.EthereumAddress,
 			user.Username,
 			user.DefaultCharacterID,
+			user.EnsName,
+			user.EnsAvatar,
+			user.EnsRefreshedAt,
+			user.Locale,
+			user.Version,
 			user.UpdatedAt,
 			user.CreatedAt,
 		).
 		ToSql()
 
-	if _, err := s.db.Exec(query, args...); err != nil {
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
 		return user, db.QueryExecuteError(err, query, args)
 	}
 
 	return user, nil
 }
 
-func (s *userStore) Update(user domain.User) (domain.User, error) {
+// Update writes the user only if it's still at user.Version and returns it with its new version. It fails with
+// db.ErrVersionMismatch when the user was modified since it was read
+func (s *userStore) Update(ctx context.Context, user domain.User) (domain.User, error) {
 	now := time.Now()
 
 	user.UpdatedAt = now
@@ -96,23 +129,93 @@ func (s *userStore) Update(user domain.User) (domain.User, error) {
 	query, args, _ := sq.Update(usersTable).
 		Set("username", user.Username).
 		Set("default_character_id", user.DefaultCharacterID).
+		Set("locale", user.Locale).
 		Set("updated_at", user.UpdatedAt).
-		Where(squirrel.Eq{"user_id": user.UserID}).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"user_id": user.UserID, "version": user.Version}).
+		Suffix("RETURNING version").
 		ToSql()
 
-	if _, err := s.db.Exec(query, args...); err != nil {
+	err := s.db.GetContext(ctx, &user.Version, query, args...)
+	switch err {
+	case nil:
+		return user, nil
+	case sql.ErrNoRows:
+		// either the version moved on or the user is gone
+		if _, err = s.Get(ctx, user.UserID); err != nil {
+			return user, err
+		}
+		return user, db.ErrVersionMismatch
+	default:
 		return user, db.QueryExecuteError(err, query, args)
 	}
+}
 
-	return user, nil
+// UpdateEns only updates the ENS profile so it can't overwrite concurrent changes to the rest of the user. A name is
+// held by a single user, it's taken off the other users holding it and their profile is refreshed again first. The
+// version of every updated user is incremented since the profile is part of the user clients see
+func (s *userStore) UpdateEns(ctx context.Context, user domain.User) (domain.User, error) {
+	now := time.Now()
+
+	user.EnsRefreshedAt = &now
+
+	err := db.RetryTransaction(ctx, s.db, db.DefaultBackoff, func(tx *sqlx.Tx) error {
+		if user.EnsName != nil {
+			query, args, _ := sq.Update(usersTable).
+				Set("ens_name", nil).
+				Set("ens_avatar", nil).
+				Set("ens_refreshed_at", nil).
+				Set("version", squirrel.Expr("version + 1")).
+				Where(squirrel.Eq{"ens_name": user.EnsName}).
+				Where(squirrel.NotEq{"user_id": user.UserID}).
+				ToSql()
+
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return db.QueryExecuteError(err, query, args)
+			}
+		}
+
+		query, args, _ := sq.Update(usersTable).
+			Set("ens_name", user.EnsName).
+			Set("ens_avatar", user.EnsAvatar).
+			Set("ens_refreshed_at", user.EnsRefreshedAt).
+			Set("version", squirrel.Expr("version + 1")).
+			Where(squirrel.Eq{"user_id": user.UserID}).
+			Suffix("RETURNING version").
+			ToSql()
+
+		if err := tx.GetContext(ctx, &user.Version, query, args...); err != nil {
+			return db.QueryExecuteError(err, query, args)
+		}
+
+		return nil
+	})
+
+	return user, err
+}
+
+// ClearDefaultCharacter unsets the default character of the user only if it's still characterID
+func (s *userStore) ClearDefaultCharacter(ctx context.Context, ethereumAddress domain.EthereumAddress, characterID string) error {
+	query, args, _ := sq.Update(usersTable).
+		Set("default_character_id", nil).
+		Set("updated_at", time.Now()).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"ethereum_address": ethereumAddress, "default_character_id": characterID}).
+		ToSql()
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return db.QueryExecuteError(err, query, args)
+	}
+
+	return nil
 }
 
-func (s *userStore) Remove(userID string) error {
+func (s *userStore) Remove(ctx context.Context, userID string) error {
 	query, args, _ := sq.Delete(usersTable).
 		Where(squirrel.Eq{"user_id": userID}).
 		ToSql()
 
-	if _, err := s.db.Exec(query, args...); err != nil {
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
 		return db.QueryExecuteError(err, query, args)
 	}
 
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"context"
+	"github.com/manta-coder/golang-serverless-example/pkg/db"
 	"github.com/manta-coder/golang-serverless-example/pkg/domain"
 	"github.com/manta-coder/golang-serverless-example/pkg/tester"
 	"github.com/segmentio/ksuid"
@@ -22,10 +24,10 @@ func testUser(t *testing.T) domain.User {
 	now := time.Now()
 
 	return domain.User{
-		EthereumAddressHex: tester.GenerateEthereumAddress(t),
-		Username:           ksuid.New().String(),
-		CreatedAt:          now,
-		UpdatedAt:          now,
+		EthereumAddress: domain.EthereumAddress(tester.GenerateAddress(t)),
+		Username:        ksuid.New().String(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 }
 
@@ -34,7 +36,7 @@ func createTestUser(t *testing.T) domain.User {
 
 	user := testUser(t)
 
-	user, err := testUserStore.Store(user)
+	user, err := testUserStore.Store(context.Background(), user)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -45,30 +47,34 @@ func createTestUser(t *testing.T) domain.User {
 func TestUserStore_Store(t *testing.T) {
 	user := testUser(t)
 
-	createdUser, err := testUserStore.Store(user)
+	createdUser, err := testUserStore.Store(context.Background(), user)
 	require.NoError(t, err)
 
 	user.UserID = createdUser.UserID
 	tester.AssertEqual(t, user, createdUser)
+
+	// addresses are unique
+	_, err = testUserStore.Store(context.Background(), user)
+	assert.ErrorIs(t, err, db.ErrConflict)
 }
 
 func TestUserStore_Get(t *testing.T) {
 	user := createTestUser(t)
 
-	foundUser, err := testUserStore.Get(user.UserID)
+	foundUser, err := testUserStore.Get(context.Background(), user.UserID)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, user, foundUser)
 
 	// should error if no user matches ID
-	_, err = testUserStore.Get(ksuid.New().String())
-	assert.Error(t, err)
+	_, err = testUserStore.Get(context.Background(), ksuid.New().String())
+	assert.ErrorIs(t, err, db.ErrNotFound)
 }
 
 func TestUserStore_FindByEthereumAddress(t *testing.T) {
 	user := createTestUser(t)
 
-	foundUser, err := testUserStore.FindByEthereumAddress(user.EthereumAddressHex)
+	foundUser, err := testUserStore.FindByEthereumAddress(context.Background(), user.EthereumAddress)
 	require.NoError(t, err)
 
 	tester.AssertEqual(t, user, foundUser)
@@ -79,24 +85,36 @@ func TestUserStore_Update(t *testing.T) {
 
 	updateUser := testUser(t)
 	updateUser.UserID = user.UserID
+	updateUser.Version = user.Version
 
-	_, err := testUserStore.Update(updateUser)
+	updatedUser, err := testUserStore.Update(context.Background(), updateUser)
 	require.NoError(t, err)
+	assert.Equal(t, user.Version+1, updatedUser.Version)
 
-	foundUser, err := testUserStore.Get(user.UserID)
+	foundUser, err := testUserStore.Get(context.Background(), user.UserID)
 	require.NoError(t, err)
 
-	updateUser.EthereumAddressHex = user.EthereumAddressHex
+	updateUser.EthereumAddress = user.EthereumAddress
 	tester.AssertEqual(t, updateUser, foundUser)
+	assert.Equal(t, updatedUser.Version, foundUser.Version)
+
+	// writing from a stale read fails instead of overwriting the previous update
+	_, err = testUserStore.Update(context.Background(), updateUser)
+	assert.ErrorIs(t, err, db.ErrVersionMismatch)
+	assert.ErrorIs(t, err, db.ErrConflict)
+
+	updateUser.UserID = "usr_unknown"
+	_, err = testUserStore.Update(context.Background(), updateUser)
+	assert.ErrorIs(t, err, db.ErrNotFound)
 }
 
 func TestUserStore_Remove(t *testing.T) {
 	user := createTestUser(t)
 
-	err := testUserStore.Remove(user.UserID)
+	err := testUserStore.Remove(context.Background(), user.UserID)
 	require.NoError(t, err)
 
 	// should error if no user matches ID
-	_, err = testUserStore.Get(user.UserID)
+	_, err = testUserStore.Get(context.Background(), user.UserID)
 	assert.Error(t, err)
 }
@@ -20,7 +20,7 @@ func NopHandlerFunc(c echo.Context) error {
 }
 
 func NewContext(opts ...ContextOptions) (echo.Context, *httptest.ResponseRecorder) {
-	e := server.NewEcho(GetLogger())
+	e := server.NewEcho(GetLogger(), server.DefaultLogConfig, server.NopMetrics, server.DefaultCORSConfig)
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
@@ -118,6 +118,13 @@ func WithData(key string, data interface{}) ContextOptions {
 	}
 }
 
+// WithHeader sets a request header
+func WithHeader(key string, value string) ContextOptions {
+	return func(req *http.Request, c echo.Context) {
+		req.Header.Set(key, value)
+	}
+}
+
 // WithMultipartForm adds a file and form fields to the context
 func WithMultipartForm(key string, file []byte, filename string, fields map[string]interface{}) ContextOptions {
 	return func(req *http.Request, c echo.Context) {
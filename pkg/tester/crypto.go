@@ -11,12 +11,18 @@ import (
 func GenerateEthereumAddress(t *testing.T) string {
 	t.Helper()
 
+	return GenerateAddress(t).Hex()
+}
+
+func GenerateAddress(t *testing.T) common.Address {
+	t.Helper()
+
 	key, err := crypto.GenerateKey()
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
 
-	return crypto.PubkeyToAddress(key.PublicKey).Hex()
+	return crypto.PubkeyToAddress(key.PublicKey)
 }
 
 func CreatePrivateKey(t *testing.T, last string) *ecdsa.PrivateKey {
@@ -0,0 +1,196 @@
+// Package tracing records the spans of requests, service calls, queries and calls to ethereum nodes with
+// OpenTelemetry
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// ServiceName identifies the spans of this service
+const ServiceName = "golang-serverless-example"
+
+// InstrumentationName names the tracer of the spans started by this module
+const InstrumentationName = "github.com/manta-coder/golang-serverless-example"
+
+const (
+	// ExporterNone records no span, incoming trace headers are still forwarded
+	ExporterNone = "none"
+	// ExporterOTLP sends spans to an OTLP/HTTP collector, such as the ADOT lambda layer
+	ExporterOTLP = "otlp"
+	// ExporterStdout prints spans, for local runs
+	ExporterStdout = "stdout"
+	// ExporterMemory keeps spans in Provider.Spans, for tests
+	ExporterMemory = "memory"
+)
+
+// Exporters are the supported exporters
+var Exporters = []interface{}{ExporterNone, ExporterOTLP, ExporterStdout, ExporterMemory}
+
+// Config describes where spans go
+type Config struct {
+	Exporter string
+	// Endpoint is the host:port of the OTLP collector, the OTEL_EXPORTER_OTLP_ variables apply when it's empty
+	Endpoint string
+	// Insecure sends spans to the collector without TLS
+	Insecure bool
+	// SampleRate is the share of traces started here that are recorded, between 0 and 1. Traces started by the
+	// caller follow its decision
+	SampleRate float64
+	// XRay reads and writes X-Amzn-Trace-Id headers and generates IDs X-Ray accepts
+	XRay bool
+	// Environment is reported with every span
+	Environment string
+}
+
+// Provider records the spans of the process
+type Provider struct {
+	sdk *sdktrace.TracerProvider
+	// Spans holds the finished spans when the exporter is ExporterMemory
+	Spans *tracetest.InMemoryExporter
+}
+
+// Setup builds the provider of config and installs it, with the propagators, as the global one every
+// instrumentation uses
+func Setup(ctx context.Context, config Config) (*Provider, error) {
+	propagators := []propagation.TextMapPropagator{propagation.TraceContext{}, propagation.Baggage{}}
+	if config.XRay {
+		propagators = append(propagators, xray.Propagator{})
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagators...))
+
+	provider := &Provider{}
+
+	var options []sdktrace.TracerProviderOption
+
+	switch config.Exporter {
+	case "", ExporterNone:
+		// otel keeps its no-op provider, spans cost nothing
+		return provider, nil
+	case ExporterOTLP:
+		var clientOptions []otlptracehttp.Option
+		if config.Endpoint != "" {
+			clientOptions = append(clientOptions, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			clientOptions = append(clientOptions, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, clientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create otlp exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterStdout:
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterMemory:
+		// spans are exported as they end so tests can read them right away
+		provider.Spans = tracetest.NewInMemoryExporter()
+		options = append(options, sdktrace.WithSyncer(provider.Spans))
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", config.Exporter)
+	}
+
+	attributes := []attribute.KeyValue{semconv.ServiceNameKey.String(ServiceName)}
+	if config.Environment != "" {
+		attributes = append(attributes, semconv.DeploymentEnvironmentKey.String(config.Environment))
+	}
+
+	options = append(options,
+		sdktrace.WithResource(resource.NewSchemaless(attributes...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRate))),
+	)
+	if config.XRay {
+		options = append(options, sdktrace.WithIDGenerator(xray.NewIDGenerator()))
+	}
+
+	provider.sdk = sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider.sdk)
+
+	return provider, nil
+}
+
+// Flush exports the spans ended so far
+func (p *Provider) Flush(ctx context.Context) error {
+	if p == nil || p.sdk == nil {
+		return nil
+	}
+
+	return p.sdk.ForceFlush(ctx)
+}
+
+// Shutdown flushes the spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.sdk == nil {
+		return nil
+	}
+
+	return p.sdk.Shutdown(ctx)
+}
+
+// flushTimeout bounds the time an invocation waits for its spans to be exported
+const flushTimeout = 2 * time.Second
+
+// Handler flushes the spans of each invocation before it returns. Lambda freezes instances between invocations,
+// spans left in the batch would only be sent on the next one, if any
+func (p *Provider) Handler(handler lambda.Handler) lambda.Handler {
+	return lambdaHandler(func(ctx context.Context, payload []byte) ([]byte, error) {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			defer cancel()
+
+			_ = p.Flush(ctx)
+		}()
+
+		return handler.Invoke(ctx, payload)
+	})
+}
+
+type lambdaHandler func(ctx context.Context, payload []byte) ([]byte, error)
+
+func (h lambdaHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return h(ctx, payload)
+}
+
+// Start starts a span of this module, name is usually the type and method traced, such as "UserService.Get"
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, options...)
+}
+
+// End records err, if any, and ends span
+func End(span trace.Span, err error, options ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End(options...)
+}
+
+// LogFields returns the trace and span IDs of ctx as zap key value pairs, nothing outside a trace
+func LogFields(ctx context.Context) []interface{} {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+
+	return []interface{}{"trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String()}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestSetup_None(t *testing.T) {
+	provider, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.Nil(t, provider.Spans)
+	assert.NoError(t, provider.Flush(context.Background()))
+	assert.NoError(t, provider.Shutdown(context.Background()))
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
+
+func TestStart(t *testing.T) {
+	provider, err := Setup(context.Background(), Config{Exporter: ExporterMemory, SampleRate: 1})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	ctx, parent := Start(context.Background(), "UserService.Get")
+	fields := LogFields(ctx)
+	_, child := Start(ctx, "db.Query")
+	End(child, errors.New("connection refused"))
+	End(parent, nil)
+
+	spans := provider.Spans.GetSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "db.Query", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+
+	assert.Equal(t, "UserService.Get", spans[1].Name)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+
+	assert.Equal(t, []interface{}{
+		"trace_id", parent.SpanContext().TraceID().String(),
+		"span_id", parent.SpanContext().SpanID().String(),
+	}, fields)
+}
+
+func TestLogFields_OutsideTrace(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, LogFields(context.Background()))
+}
+
+func TestSetup_XRay(t *testing.T) {
+	provider, err := Setup(context.Background(), Config{Exporter: ExporterMemory, SampleRate: 1, XRay: true})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	// calls carry both the W3C and the X-Ray headers
+	ctx, span := Start(context.Background(), "UserService.Get")
+	defer span.End()
+
+	header := propagation.HeaderCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, header)
+
+	assert.Contains(t, header.Get("X-Amzn-Trace-Id"), "Root=1-")
+	assert.NotEmpty(t, header.Get("traceparent"))
+
+	// traces started by API Gateway are continued
+	incoming := propagation.HeaderCarrier{}
+	incoming.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	remote := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), incoming))
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", remote.TraceID().String())
+}
+
+func TestProvider_Handler(t *testing.T) {
+	provider, err := Setup(context.Background(), Config{Exporter: ExporterMemory, SampleRate: 1})
+	require.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	handler := provider.Handler(lambda.NewHandler(func(ctx context.Context) (string, error) {
+		_, span := Start(ctx, "IndexerService.Sync")
+		span.End()
+
+		return "done", nil
+	}))
+
+	response, err := handler.Invoke(context.Background(), []byte("{}"))
+	require.NoError(t, err)
+	assert.Equal(t, `"done"`, string(response))
+	assert.Len(t, provider.Spans.GetSpans(), 1)
+}
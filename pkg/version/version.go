@@ -0,0 +1,31 @@
+// Package version describes the running build. The variables are set at build time by the Makefiles:
+//
+// go build -ldflags "-X github.com/manta-coder/golang-serverless-example/pkg/version.Commit=$(git rev-parse HEAD)"
+package version
+
+var (
+	// Version is the release, SNAPSHOT for local builds
+	Version = "SNAPSHOT"
+	// Commit is the git SHA the build was made from
+	Commit = "unknown"
+	// BuildTime is when the build was made, in RFC 3339
+	BuildTime = "unknown"
+)
+
+// Info is the build and the environment it runs in
+type Info struct {
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+	BuildTime   string `json:"build_time"`
+	Environment string `json:"environment"`
+}
+
+// Get returns the running build in environment
+func Get(environment string) Info {
+	return Info{
+		Version:     Version,
+		Commit:      Commit,
+		BuildTime:   BuildTime,
+		Environment: environment,
+	}
+}